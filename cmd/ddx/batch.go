@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shioshosho/diff-docx/internal/progress"
+	"github.com/shioshosho/diff-docx/pkg/ddx"
+)
+
+// batchOptions controls a directory comparison
+type batchOptions struct {
+	parallel int    // documents compared at once
+	format   string // text or json
+	exitCode bool
+	failOn   string
+	strict   bool // fail when a comparison skipped images or reported warnings
+}
+
+// batchEntry is the outcome for one document name in a batch
+type batchEntry struct {
+	Name   string      `json:"name"`
+	Status string      `json:"status"` // same, changed, failed, removed or added
+	Report *ddx.Report `json:"report,omitempty"`
+	Error  string      `json:"error,omitempty"`
+
+	file1, file2 string // documents to compare; unset for removed and added
+	outputDir    string // output directory of this comparison
+	result       *ddx.Result
+}
+
+// isDir reports whether path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// docxNames lists the .docx files directly inside dir, skipping Word's
+// ~$ lock files
+func docxNames(dir string) (map[string]bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type().IsRegular() && strings.EqualFold(filepath.Ext(name), ".docx") && !strings.HasPrefix(name, "~$") {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+// runBatch compares the documents with the same name in dir1 and dir2, up to
+// b.parallel at once, each into its own subdirectory of the output directory.
+// base carries the options shared by every comparison; ctx bounds them all.
+func runBatch(ctx context.Context, dir1, dir2 string, base ddx.Options, b batchOptions) int {
+	names1, err := docxNames(dir1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to read %s: %v\n", dir1, err)
+		return 1
+	}
+	names2, err := docxNames(dir2)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to read %s: %v\n", dir2, err)
+		return 1
+	}
+
+	var entries []batchEntry
+	for name := range names1 {
+		if names2[name] {
+			entries = append(entries, batchEntry{Name: name})
+		} else {
+			entries = append(entries, batchEntry{Name: name, Status: "removed"})
+		}
+	}
+	for name := range names2 {
+		if !names1[name] {
+			entries = append(entries, batchEntry{Name: name, Status: "added"})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	outputDir := base.OutputDir
+	if outputDir == "" {
+		outputDir = ddx.DefaultOutputDir
+	}
+	for i, e := range entries {
+		if e.Status == "" {
+			entries[i].file1 = filepath.Join(dir1, e.Name)
+			entries[i].file2 = filepath.Join(dir2, e.Name)
+			entries[i].outputDir = filepath.Join(outputDir, ddx.DocxBaseName(e.Name))
+		}
+	}
+
+	compareEntries(ctx, entries, base, b.parallel)
+	if b.format == "json" {
+		if err := writeJSON(entries); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	} else {
+		printBatchSummary("=== Batch Comparison ===", entries, dir1, dir2)
+	}
+	return batchStatus(entries, b)
+}
+
+// runMulti compares every document after the first in files against the
+// first, each into <output-dir>/<name>. args are the arguments the files
+// were resolved from, used for the entry names.
+func runMulti(ctx context.Context, files, args []string, base ddx.Options, b batchOptions) int {
+	outputDir := base.OutputDir
+	if outputDir == "" {
+		outputDir = ddx.DefaultOutputDir
+	}
+
+	var entries []batchEntry
+	used := make(map[string]bool)
+	for i, file := range files[1:] {
+		name := ddx.DocxBaseName(args[i+1])
+		// Variants with the same file name from different places get a suffix
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s-%d", ddx.DocxBaseName(args[i+1]), n)
+		}
+		used[name] = true
+		entries = append(entries, batchEntry{
+			Name:      name,
+			file1:     files[0],
+			file2:     file,
+			outputDir: filepath.Join(outputDir, name),
+		})
+	}
+
+	compareEntries(ctx, entries, base, b.parallel)
+	if b.format == "json" {
+		if err := writeJSON(entries); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	} else {
+		printBatchSummary("=== Comparison against "+filepath.Base(args[0])+" ===", entries, "", "")
+	}
+	return batchStatus(entries, b)
+}
+
+// compareEntries runs the comparisons of the entries without a status, up
+// to parallel at once, and records their outcome. base carries the options
+// shared by every comparison; ctx bounds them all. One at a time, each
+// comparison shows its steps on a bar labeled "file 3/20: <name>"; in
+// parallel, one bar counts the finished comparisons.
+func compareEntries(ctx context.Context, entries []batchEntry, base ddx.Options, parallel int) {
+	// Bound external tools to the CPU count however many documents run at once
+	base.ToolLimiter = ddx.NewToolLimiter(runtime.NumCPU())
+	base.Progress = nil
+
+	var pending []int
+	position := make(map[int]int) // entry index -> 1-based position among pending
+	for i, e := range entries {
+		if e.Status == "" {
+			pending = append(pending, i)
+			position[i] = len(pending)
+		}
+	}
+
+	sequential := parallel <= 1
+	var bar *progress.Bar
+	if !sequential {
+		bar = progress.New(len(pending))
+	}
+	var mu sync.Mutex
+	done := 0
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(max(parallel, 1), len(pending)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				e := &entries[i]
+				opts := base
+				opts.File1 = e.file1
+				opts.File2 = e.file2
+				opts.OutputDir = e.outputDir
+				var fileBar *progress.Bar
+				if sequential {
+					fileBar = progress.New(ddx.Steps)
+					fileBar.SetPrefix(fmt.Sprintf("file %d/%d: %s", position[i], len(pending), e.Name))
+					opts.Progress = barProgress(fileBar)
+				}
+
+				result, err := ddx.RunContext(ctx, opts)
+				if ctx.Err() != nil {
+					fileBar.Abort(ctx.Err().Error())
+				} else {
+					fileBar.Done()
+				}
+				switch {
+				case err != nil:
+					e.Status, e.Error = "failed", err.Error()
+				case result.TextChanged || result.StyleDiff != "" || result.ImagesChanged():
+					e.Status, e.result = "changed", result
+				default:
+					e.Status, e.result = "same", result
+				}
+				if e.result != nil {
+					report := result.Report()
+					e.Report = &report
+				}
+
+				mu.Lock()
+				done++
+				bar.Set(done, "Compared "+e.Name)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, i := range pending {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	if ctx.Err() != nil {
+		bar.Abort(ctx.Err().Error())
+	} else {
+		bar.Done()
+	}
+}
+
+// batchStatus returns the exit status for the outcome of a batch
+func batchStatus(entries []batchEntry, b batchOptions) int {
+	status := 0
+	for _, e := range entries {
+		if e.result != nil && b.strict && reportIncomplete(e.Name, e.result) {
+			status = 1
+		}
+		switch {
+		case e.Status == "failed":
+			status = 1
+		case e.result != nil && len(e.result.Drift) > 0:
+			status = 1
+		case e.result != nil && b.exitCode && shouldFail(b.failOn, e.result):
+			status = 1
+		case b.exitCode && (e.Status == "added" || e.Status == "removed") && b.failOn != "images":
+			status = 1
+		}
+	}
+	return status
+}
+
+// printBatchSummary prints one line per document and the totals under
+// header. dir1 and dir2 name the sides of removed and added documents.
+func printBatchSummary(header string, entries []batchEntry, dir1, dir2 string) {
+	fmt.Println(header)
+	fmt.Println()
+	counts := make(map[string]int)
+	for _, e := range entries {
+		counts[e.Status]++
+		switch e.Status {
+		case "same":
+			fmt.Printf("  [SAME] %s\n", e.Name)
+		case "changed":
+			r := e.result
+			m := r.MatchResult
+			images := len(m.Different) + len(m.OnlyIn1) + len(m.OnlyIn2)
+			fmt.Printf("  [DIFF] %s (%s, %s changed) -> %s\n", e.Name, r.DiffStat, countImages(images), r.DiffPath)
+		case "failed":
+			fmt.Printf("  [FAIL] %s: %s\n", e.Name, e.Error)
+		case "removed":
+			fmt.Printf("  [DEL]  %s (only in %s)\n", e.Name, dir1)
+		case "added":
+			fmt.Printf("  [ADD]  %s (only in %s)\n", e.Name, dir2)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("  %d compared: %d changed, %d unchanged, %d failed",
+		counts["same"]+counts["changed"]+counts["failed"], counts["changed"], counts["same"], counts["failed"])
+	if dir1 != "" || dir2 != "" {
+		fmt.Printf("; %d only in %s, %d only in %s", counts["removed"], dir1, counts["added"], dir2)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shioshosho/diff-docx/pkg/ddx"
+)
+
+// runClean implements "ddx clean": it removes the diff output directory
+// after asking for confirmation.
+func runClean(args []string) int {
+	flags := flag.NewFlagSet("clean", flag.ContinueOnError)
+	outputDir := flags.String("output-dir", ddx.DefaultOutputDir, "Diff output directory to remove")
+	force := flags.Bool("force", false, "Remove without asking for confirmation")
+	dryRun := flags.Bool("dry-run", false, "List what would be removed without removing anything")
+	flags.Usage = printCleanUsage
+
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	dir := filepath.Clean(*outputDir)
+	if err := checkCleanTarget(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	info, err := os.Lstat(dir)
+	if os.IsNotExist(err) {
+		fmt.Printf("Nothing to clean: %s does not exist\n", dir)
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "Error: %s is not a directory\n", dir)
+		return 1
+	}
+
+	var files []string
+	var size int64
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		files = append(files, path)
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to read %s: %v\n", dir, err)
+		return 1
+	}
+
+	summary := fmt.Sprintf("%s/ (%d files, %s)", dir, len(files), ddx.FormatBytes(size))
+	if *dryRun {
+		for _, f := range files {
+			fmt.Printf("  %s\n", f)
+		}
+		fmt.Printf("Would remove %s\n", summary)
+		return 0
+	}
+
+	if !*force && !confirm(fmt.Sprintf("Remove %s? [y/N] ", summary)) {
+		fmt.Println("Aborted.")
+		return 1
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to remove %s: %v\n", dir, err)
+		return 1
+	}
+	fmt.Printf("Removed %s\n", summary)
+	return 0
+}
+
+// checkCleanTarget refuses to remove the working directory, one of its
+// ancestors, or the filesystem root.
+func checkCleanTarget(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	rel, err := filepath.Rel(abs, cwd)
+	if err == nil && (rel == "." || !strings.HasPrefix(rel, "..")) {
+		return fmt.Errorf("refusing to remove %s: it contains the working directory", dir)
+	}
+	return nil
+}
+
+// confirm asks a yes/no question on stdin. Anything but y/yes is a no.
+func confirm(prompt string) bool {
+	fmt.Print(prompt)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func printCleanUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  ddx clean [options]")
+	fmt.Println()
+	fmt.Println("Remove the diff output directory left by previous runs.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --output-dir <dir>  Diff output directory to remove (default: diff)")
+	fmt.Println("  --force             Remove without asking for confirmation")
+	fmt.Println("  --dry-run           List what would be removed without removing anything")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// tool describes an external command ddx runs
+type tool struct {
+	name    string
+	purpose string
+	need    string            // "required", "optional" or "converter" (one of markitdown and pandoc)
+	install map[string]string // install command per GOOS; "" covers the rest
+}
+
+// tools lists the external commands checked by "ddx doctor"
+var tools = []tool{
+	{
+		name: "markitdown", purpose: "docx to markdown conversion", need: "converter",
+		install: map[string]string{"": "pip install markitdown  (or: uv tool install markitdown)"},
+	},
+	{
+		name: "pandoc", purpose: "docx to markdown conversion when markitdown is missing", need: "converter",
+		install: map[string]string{
+			"linux":   "sudo apt install pandoc",
+			"darwin":  "brew install pandoc",
+			"windows": "winget install JohnMacFarlane.Pandoc",
+		},
+	},
+	{
+		name: "magick", purpose: "image comparison and diff images (ImageMagick 7); without it only PNG, JPEG and GIF are compared", need: "optional",
+		install: map[string]string{
+			"linux":   "see https://imagemagick.org/script/download.php (ImageMagick 7; distro packages are often 6)",
+			"darwin":  "brew install imagemagick",
+			"windows": "winget install ImageMagick.ImageMagick",
+		},
+	},
+	{
+		name: "diff", purpose: "unified diff of the markdown", need: "required",
+		install: map[string]string{
+			"linux":   "sudo apt install diffutils",
+			"darwin":  "xcode-select --install",
+			"windows": "scoop install diffutils",
+		},
+	},
+	{
+		name: "delta", purpose: "highlighted diff view; falls back to diff -u", need: "optional",
+		install: map[string]string{
+			"linux":   "sudo apt install git-delta",
+			"darwin":  "brew install git-delta",
+			"windows": "winget install dandavison.delta",
+		},
+	},
+	{
+		name: "libreoffice", purpose: "vector images (wmf/emf/svg) with --convert-png=false", need: "optional",
+		install: map[string]string{
+			"linux":   "sudo apt install libreoffice",
+			"darwin":  "brew install --cask libreoffice",
+			"windows": "winget install TheDocumentFoundation.LibreOffice",
+		},
+	},
+}
+
+// needLabel describes whether the tool is required
+func (t tool) needLabel() string {
+	if t.need == "converter" {
+		return "one of markitdown and pandoc is required"
+	}
+	return t.need
+}
+
+// installHint returns the install suggestion for the current OS
+func (t tool) installHint() string {
+	if hint, ok := t.install[runtime.GOOS]; ok {
+		return hint
+	}
+	return t.install[""]
+}
+
+// toolVersion returns the first line of "<name> --version" and whether the
+// tool is on PATH
+func toolVersion(name string) (string, bool) {
+	if _, err := exec.LookPath(name); err != nil {
+		return "", false
+	}
+	out, err := exec.Command(name, "--version").Output()
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if err != nil || strings.TrimSpace(line) == "" {
+		return "(version unknown)", true
+	}
+	return strings.TrimSpace(line), true
+}
+
+// runDoctor implements "ddx doctor": it checks every external tool, prints
+// the detected versions and suggests how to install missing ones.
+func runDoctor(args []string) int {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	flags.Usage = printDoctorUsage
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	fmt.Println("=== Dependencies ===")
+	fmt.Println()
+
+	converters := 0
+	missingRequired := false
+	for _, t := range tools {
+		version, ok := toolVersion(t.name)
+		if ok {
+			if t.need == "converter" {
+				converters++
+			}
+			fmt.Printf("  [OK]      %-12s %s\n", t.name, version)
+			if t.name == "magick" && !strings.Contains(version, "ImageMagick 7") {
+				fmt.Printf("            %-12s warning: ImageMagick 7 is required\n", "")
+			}
+			continue
+		}
+
+		if t.need == "required" {
+			missingRequired = true
+		}
+		fmt.Printf("  [MISSING] %-12s %s (%s)\n", t.name, t.purpose, t.needLabel())
+		fmt.Printf("            %-12s install: %s\n", "", t.installHint())
+	}
+
+	fmt.Println()
+	if converters == 0 {
+		fmt.Println("  No markdown converter found: install markitdown or pandoc.")
+		missingRequired = true
+	}
+	if missingRequired {
+		fmt.Println("  Required tools are missing; ddx cannot compare documents yet.")
+		return 1
+	}
+	fmt.Println("  All required tools are installed.")
+	return 0
+}
+
+func printDoctorUsage() {
+	fmt.Println("ddx doctor - Check external tools")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  ddx doctor")
+	fmt.Println()
+	fmt.Println("Checks markitdown, pandoc, magick, diff, delta and libreoffice, prints their")
+	fmt.Println("versions and suggests install commands for missing ones. Exits with status 1")
+	fmt.Println("when a required tool is missing.")
+}
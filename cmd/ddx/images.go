@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/shioshosho/diff-docx/internal/image"
+)
+
+// runImages implements "ddx images <dir1> <dir2>": it matches two folders of
+// already-extracted images without any docx involved.
+func runImages(args []string) int {
+	fs := flag.NewFlagSet("images", flag.ContinueOnError)
+	verbose := fs.Bool("verbose", false, "Show verbose output")
+	summaryOnly := fs.Bool("summary-only", false, "Print only aggregate image counts instead of one line per image")
+	outputDir := fs.String("output-dir", "diff", "Directory for diff output")
+	convertPNG := fs.Bool("convert-png", true, "Convert vector images (wmf/emf/svg) to PNG via ImageMagick before comparison")
+	stripMetadata := fs.Bool("strip-metadata", false, "Auto-orient and strip metadata (EXIF etc.) from raster images before comparison")
+	fs.Usage = printImagesUsage
+
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if fs.NArg() < 2 {
+		printImagesUsage()
+		return 0
+	}
+
+	dir1, dir2 := fs.Arg(0), fs.Arg(1)
+	for _, dir := range []string{dir1, dir2} {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "Error: %s is not a directory\n", dir)
+			return 1
+		}
+	}
+
+	// Without ImageMagick, images are compared in Go as in the main command
+	noMagick := false
+	if _, err := exec.LookPath("magick"); err != nil {
+		if *stripMetadata {
+			fmt.Fprintf(os.Stderr, "Error: --strip-metadata needs ImageMagick (magick), which is not installed\n")
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "Warning: ImageMagick not found, comparing only PNG, JPEG and GIF images without it\n")
+		noMagick = true
+	}
+
+	images1, err := image.ImagesFromDir(dir1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to read %s: %v\n", dir1, err)
+		return 1
+	}
+	images2, err := image.ImagesFromDir(dir2)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to read %s: %v\n", dir2, err)
+		return 1
+	}
+
+	diffImgsDir := filepath.Join(*outputDir, "imgs")
+	if err := os.MkdirAll(diffImgsDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to create directory %s: %v\n", diffImgsDir, err)
+		return 1
+	}
+
+	matchResult, err := image.MatchImageSets(images1, images2, diffImgsDir, image.MatchOptions{
+		ConvertPNG:    *convertPNG && !noMagick,
+		StripMetadata: *stripMetadata,
+		NoMagick:      noMagick,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to match images: %v\n", err)
+		return 1
+	}
+
+	fmt.Println("=== Image Comparison ===")
+	fmt.Println()
+	printMatchSummary(matchResult, displayOptions{
+		verbose:     *verbose,
+		summaryOnly: *summaryOnly,
+	})
+
+	if len(matchResult.Different) > 0 {
+		fmt.Println()
+		fmt.Println("=== Output ===")
+		fmt.Printf("  %s/ (%d diff images)\n", diffImgsDir, len(matchResult.Different))
+	}
+
+	return 0
+}
+
+func printImagesUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  ddx images [options] <dir1> <dir2>")
+	fmt.Println()
+	fmt.Println("Match two folders of images with the same content-based matching used for docx media.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --verbose           Show verbose output")
+	fmt.Println("  --summary-only      Print only aggregate image counts instead of one line per image")
+	fmt.Println("  --output-dir <dir>  Directory for diff output (default: diff)")
+	fmt.Println("  --convert-png       Convert vector images (wmf/emf/svg) to PNG before comparison (default: true)")
+	fmt.Println("  --strip-metadata    Auto-orient and strip EXIF/metadata from raster images before comparison")
+}
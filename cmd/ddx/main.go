@@ -1,26 +1,138 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/shioshosho/diff-docx/internal/diff"
-	"github.com/shioshosho/diff-docx/internal/docx"
 	"github.com/shioshosho/diff-docx/internal/image"
 	"github.com/shioshosho/diff-docx/internal/markdown"
 	"github.com/shioshosho/diff-docx/internal/progress"
+	"github.com/shioshosho/diff-docx/internal/source"
+	"github.com/shioshosho/diff-docx/pkg/ddx"
 )
 
 const version = "1.0.0"
 
 func main() {
+	os.Exit(run())
+}
+
+// run dispatches subcommands and falls back to comparing two documents.
+func run() int {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "images":
+			return runImages(os.Args[2:])
+		case "clean":
+			return runClean(os.Args[2:])
+		case "doctor":
+			return runDoctor(os.Args[2:])
+		}
+	}
+	return runCompare()
+}
+
+func runCompare() int {
 	showVersion := flag.Bool("version", false, "Show version")
 	showHelp := flag.Bool("help", false, "Show help")
 	verbose := flag.Bool("verbose", false, "Show verbose output")
+	noDelta := flag.Bool("no-delta", false, "Show the markdown diff with plain diff -u even when delta is installed")
+	theme := flag.String("theme", "", "delta syntax theme, e.g. Nord, regardless of gitconfig (ignored without delta)")
+	var deltaArgs stringList
+	flag.Var(&deltaArgs, "delta-arg", "Extra option passed to delta, e.g. --side-by-side or --syntax-theme=Nord (repeatable)")
+	summaryOnly := flag.Bool("summary-only", false, "Print only aggregate image counts instead of one line per image")
+	outputDir := flag.String("output-dir", ddx.DefaultOutputDir, "Directory for diff output")
+	gitignore := flag.Bool("gitignore", false, "Write a .gitignore into the output directory that ignores everything but diff.md")
+	parallelFiles := flag.Int("parallel-files", 1, "Documents compared at once for two directories or more than two documents")
+	emptyBaseline := flag.Bool("empty-baseline", false, "Compare a single document against an empty one, listing all of its content as added")
+	label1 := flag.String("label1", "", "Name for the first document in output paths and the diff (default: its file name)")
+	label2 := flag.String("label2", "", "Name for the second document in output paths and the diff (default: its file name)")
+	keepRendered := flag.Bool("keep-rendered", false, "Save both markdown files to the output directory with image links into diff/imgs/")
+	mdDir := flag.String("md-dir", "", "Directory for the per-document <docx>.md files (default: the output directory)")
 	convertPNG := flag.Bool("convert-png", true, "Convert vector images (wmf/emf/svg) to PNG via ImageMagick before comparison")
+	stripMetadata := flag.Bool("strip-metadata", false, "Auto-orient and strip metadata (EXIF etc.) from raster images before comparison")
+	normalizeUnicode := flag.Bool("normalize-unicode", false, "NFC-normalize markdown before diffing")
+	sanitize := flag.Bool("sanitize", false, "Show control characters such as form feeds as visible symbols (␌) before diffing")
+	sharedImageNames := flag.Bool("shared-image-names", false, "Refer to images identical in both documents as shared/imageN.ext in the diff instead of by the first document's file name")
+	forbidSameFile := flag.Bool("forbid-same-file", false, "Fail instead of warning when both inputs are the same file")
+	followSymlinks := flag.Bool("follow-symlinks", true, "Resolve symlinked inputs to their targets when checking inputs")
+	noFollowSymlinks := flag.Bool("no-follow-symlinks", false, "Treat symlinked inputs as the links themselves when checking inputs")
+	noCleanup := flag.Bool("no-cleanup", false, "Debug: keep the extracted documents in their temp directories and print the paths")
+	keepIdenticalDiffs := flag.Bool("keep-identical-diffs", false, "Debug: keep the heatmaps of identical image pairs as <name1>-<name2>.identical.<ext>")
+	manifest := flag.Bool("manifest", false, "Write diff/imgs/manifest.json with the SHA-256 of each diff image")
+	verifyManifest := flag.Bool("verify-manifest", false, "Compare diff images against the existing diff/imgs/manifest.json and exit with status 1 on drift")
+	flatten := flag.Bool("flatten", false, "Copy original images to diff/imgs/<docx>__<name> instead of diff/imgs/original/<docx>/<name>")
+	onlyChanged := flag.Bool("only-changed-images", false, "Copy originals of changed image pairs only, not of added or removed images")
+	includeUnchanged := flag.Bool("include-unchanged-images", false, "Also copy originals of unchanged images to diff/imgs/original/")
+	section := flag.String("section", "", "Diff only the markdown under the heading with this title")
+	frontMatter := flag.Bool("front-matter", false, "Prepend a YAML front-matter block to diff.md")
+	diffFormat := flag.String("diff-format", diff.FormatUnified, "Layout of diff.md: unified (fenced diff), side-by-side (GFM table), or patch (unified plus an applicable diff.patch)")
+	fenceLang := flag.String("fence-lang", "diff", `Info string of the code fence in diff.md, e.g. diff or text ("" or none for a bare fence)`)
+	styleDiff := flag.Bool("style-diff", false, "Also diff paragraph styles and run formatting (headings, bold, color, font, size)")
+	detectMoves := flag.Bool("detect-moves", false, "Report blocks moved without changes under ## Moved Sections in diff.md")
+	includeHeaders := flag.Bool("include-headers", false, "Diff the text of page headers (## Headers)")
+	includeFooters := flag.Bool("include-footers", false, "Diff the text of page footers (## Footers)")
+	includeFootnotes := flag.Bool("include-footnotes", false, "Diff the footnotes (## Footnotes)")
+	tableDiff := flag.Bool("table-diff", false, "Append cell-level table changes to diff.md")
+	diffImageName := flag.String("diff-image-name", image.DefaultDiffName, "Name of diff images without extension; {name1} and {name2} stand for the image names")
+	diffTempSuffix := flag.String("diff-temp-suffix", image.DefaultTempSuffix, "Suffix of the diff image magick compare writes before it is renamed")
+	diffImageFormat := flag.String("diff-image-format", "png", "Format of generated diff images: png, webp, or avif")
+	metrics := flag.String("metrics", "psnr", "Comma-separated metrics to report for changed images: psnr, ssim, ae (changed pixel count)")
+	showPixelCount := flag.Bool("show-pixel-count", false, "Report the number of changed pixels for changed images (same as adding ae to --metrics)")
+	crossFormat := flag.Bool("cross-format", false, "Also match images left in one document against identical images of another raster format, e.g. PNG re-exported as JPEG")
+	noMagick := flag.Bool("no-magick", false, "Compare PNG, JPEG and GIF images in Go instead of with ImageMagick; other formats are skipped")
+	fuzz := flag.Float64("fuzz", 0, "Color distance in percent within which magick compare treats pixels as equal, e.g. 2")
+	losslessThreshold := flag.Float64("psnr-threshold-lossless", image.PSNRThreshold, "PSNR below which lossless image pairs (PNG, BMP, GIF, TIFF, vector) count as different")
+	lossyThreshold := flag.Float64("psnr-threshold-lossy", image.LossyPSNRThreshold, "PSNR below which lossy image pairs (JPEG, WebP) count as different")
+	ignoreCase := flag.Bool("ignore-case", false, "Treat text lines that differ only in letter case as unchanged (images are compared as usual)")
+	ignoreWhitespace := flag.Bool("ignore-whitespace", false, "Treat text lines that differ only in white space as unchanged")
+	var preFilters stringList
+	flag.Var(&preFilters, "pre-filter", "Replace matches of a regex in both markdown texts before diffing, as <regex>=<replacement> (repeatable)")
+	preFilterFile := flag.String("pre-filter-file", "", "File listing --pre-filter rules, one per line")
+	diffAlgorithm := flag.String("diff-algorithm", diff.AlgorithmMyers, "Text diff algorithm: myers (diff -u), patience or histogram")
+	direction := flag.String("direction", ddx.DirectionBoth, "Changes to report: both, or forward for only removals and modifications relative to the first document")
+	sortBy := flag.String("sort-by", "", "Order of changed images in the summary and reports: psnr or name (default: matching order)")
+	jsonOutput := flag.Bool("json", false, "Print a JSON report to stdout instead of the diff view and summary (same as --format json)")
+	format := flag.String("format", "text", "Output format on stdout: text, json, or gitlab (merge-request notes)")
+	reportTemplate := flag.String("report-template", "", "Also render a report with a Go text/template file, or the built-in markdown or html template")
+	reportOutput := flag.String("report-output", "", "Path of the --report-template report (default: <output-dir>/report.<ext>)")
+	textWeight := flag.Float64("text-weight", 1, "Weight of text similarity in the overall similarity score")
+	imageWeight := flag.Float64("image-weight", 1, "Weight of image similarity in the overall similarity score")
+	converter := flag.String("converter", "auto", "Docx-to-markdown converter: auto, markitdown, or pandoc")
+	encoding := flag.String("encoding", markdown.DefaultEncoding, "Encoding of the markitdown output, e.g. utf-8, shift_jis, or euc-jp (pandoc always writes UTF-8)")
+	retries := flag.Int("retries", 1, "Retries for transient markitdown/magick failures")
+	timeout := flag.Duration("timeout", 60*time.Second, "Download timeout for http(s) URL arguments (0: no limit)")
+	watch := flag.Bool("watch", false, "Compare again whenever a local input file changes, until interrupted")
+	deadline := flag.Duration("deadline", 0, "Abort the whole comparison after this long, killing running tools (0: no limit)")
+	since := flag.String("since", "", "Only compare images whose zip modification time is at or after this time (RFC 3339 or YYYY-MM-DD)")
+	maxImages := flag.Int("max-images", ddx.DefaultMaxImages, "Abort when a document has more images than this (0: no limit)")
+	maxImageDimension := flag.Int("max-image-dimension", 0, "Downscale image pairs whose width or height exceeds this many pixels before comparison (0: no limit)")
+	timing := flag.Bool("timing", false, "Print the time each pipeline stage took (also shown with --verbose)")
+	strict := flag.Bool("strict", false, "Fail when any image is skipped or the conversion reports a warning")
+	exitCode := flag.Bool("exit-code", false, "Exit with status 1 when differences are found")
+	failOn := flag.String("fail-on", "any", "Differences that cause a non-zero exit with --exit-code: text, images, or any")
+	var mediaPrefixes stringList
+	flag.Var(&mediaPrefixes, "media-prefix", "Additional archive path prefix to treat as media, e.g. word/media2/ (repeatable)")
+	var ignoreHashes stringList
+	flag.Var(&ignoreHashes, "ignore-image-hash", "SHA-256 of an image to leave out of the comparison (repeatable)")
+	var ignoreAlt stringList
+	flag.Var(&ignoreAlt, "ignore-alt", "Skip images whose alt text contains this substring, case-insensitively (repeatable)")
+	imageRange := flag.String("image-range", "", "Compare only the media at positions <start>:<end> of each document in natural (numeric) name order, e.g. 10:20")
+	changedSectionsOnly := flag.Bool("changed-sections-only", false, "Compare only the images referenced in heading sections whose text changed")
+	ignoreHashesFile := flag.String("ignore-image-hashes-file", "", "File listing SHA-256 digests of images to leave out, one per line")
 	flag.BoolVar(showVersion, "v", false, "Show version (shorthand)")
 	flag.BoolVar(showHelp, "h", false, "Show help (shorthand)")
 
@@ -28,30 +140,662 @@ func main() {
 
 	if *showVersion {
 		fmt.Printf("ddx version %s\n", version)
-		os.Exit(0)
+		return 0
+	}
+
+	args := flag.Args()
+	if *emptyBaseline {
+		if len(args) > 1 {
+			fmt.Fprintf(os.Stderr, "Error: --empty-baseline takes a single document\n")
+			return 1
+		}
+		args = append([]string{source.NullArg}, args...)
 	}
 
-	if *showHelp || flag.NArg() < 2 {
+	if *showHelp || len(args) < 2 {
 		printUsage()
-		os.Exit(0)
+		return 0
 	}
 
-	file1 := flag.Arg(0)
-	file2 := flag.Arg(1)
+	if *jsonOutput {
+		*format = "json"
+	}
+	if !slices.Contains(outputFormats, *format) {
+		fmt.Fprintf(os.Stderr, "Error: invalid --format value %q (expected text, json, or gitlab)\n", *format)
+		return 1
+	}
+
+	var tmpl *ddx.ReportTemplate
+	if *reportTemplate != "" {
+		t, err := ddx.LoadReportTemplate(*reportTemplate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		tmpl = t
+	} else if *reportOutput != "" {
+		fmt.Fprintf(os.Stderr, "Error: --report-output requires --report-template\n")
+		return 1
+	}
 
-	if err := validateInputFiles(file1, file2); err != nil {
+	if strings.ContainsAny(*fenceLang, "`\n\r") {
+		fmt.Fprintf(os.Stderr, "Error: invalid --fence-lang value %q\n", *fenceLang)
+		return 1
+	}
+	if *fenceLang == "" {
+		*fenceLang = "none"
+	}
+
+	if !validFailOn(*failOn) {
+		fmt.Fprintf(os.Stderr, "Error: invalid --fail-on value %q (expected text, images, or any)\n", *failOn)
+		return 1
+	}
+
+	if !slices.Contains(image.DiffFormats, *diffImageFormat) {
+		fmt.Fprintf(os.Stderr, "Error: invalid --diff-image-format value %q (expected png, webp, or avif)\n", *diffImageFormat)
+		return 1
+	}
+
+	if image.CheckDiffName(*diffImageName) != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --diff-image-name value %q (expected a file name such as %s)\n", *diffImageName, image.DefaultDiffName)
+		return 1
+	}
+	if strings.ContainsAny(*diffTempSuffix, `/\`) {
+		fmt.Fprintf(os.Stderr, "Error: invalid --diff-temp-suffix value %q\n", *diffTempSuffix)
+		return 1
+	}
+
+	if *showPixelCount {
+		*metrics += ",ae"
+	}
+	metricNames, err := parseMetrics(*metrics)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return 1
+	}
+
+	if *sortBy != "" && !slices.Contains(image.SortKeys, *sortBy) {
+		fmt.Fprintf(os.Stderr, "Error: invalid --sort-by value %q (expected psnr or name)\n", *sortBy)
+		return 1
+	}
+
+	if err := markdown.CheckEncoding(*encoding); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if err := diff.CheckDeltaArgs(deltaArgs); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *theme != "" {
+		deltaArgs = append(deltaArgs, "--syntax-theme="+*theme)
+	}
+
+	if !slices.Contains(diff.Formats, *diffFormat) {
+		fmt.Fprintf(os.Stderr, "Error: invalid --diff-format value %q (expected unified, side-by-side, or patch)\n", *diffFormat)
+		return 1
+	}
+
+	if !slices.Contains(diff.Algorithms, *diffAlgorithm) {
+		fmt.Fprintf(os.Stderr, "Error: invalid --diff-algorithm value %q (expected myers, patience, or histogram)\n", *diffAlgorithm)
+		return 1
+	}
+
+	if !slices.Contains(ddx.Directions, *direction) {
+		fmt.Fprintf(os.Stderr, "Error: invalid --direction value %q (expected both or forward)\n", *direction)
+		return 1
+	}
+
+	if *textWeight < 0 || *imageWeight < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --text-weight and --image-weight must not be negative\n")
+		return 1
+	}
+
+	if *losslessThreshold <= 0 || *lossyThreshold <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: --psnr-threshold-lossless and --psnr-threshold-lossy must be positive\n")
+		return 1
+	}
+
+	if *fuzz < 0 || *fuzz > 100 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --fuzz value %v (expected a percentage from 0 to 100)\n", *fuzz)
+		return 1
+	}
+
+	if *parallelFiles < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --parallel-files must be at least 1\n")
+		return 1
+	}
+
+	if *maxImages < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --max-images must not be negative\n")
+		return 1
+	}
+
+	if *maxImageDimension < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --max-image-dimension must not be negative\n")
+		return 1
+	}
+
+	if *retries < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --retries must not be negative\n")
+		return 1
+	}
+
+	sinceTime, err := parseSince(*since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *ignoreHashesFile != "" {
+		hashes, err := readHashList(*ignoreHashesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		ignoreHashes = append(ignoreHashes, hashes...)
+	}
+	if *preFilterFile != "" {
+		rules, err := readFilterFile(*preFilterFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		preFilters = append(preFilters, rules...)
+	}
+	if _, err := markdown.ParseFilters(preFilters); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *imageRange != "" {
+		if _, err := image.ParseImageRange(*imageRange); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	for _, h := range ignoreHashes {
+		if !validSHA256(h) {
+			fmt.Fprintf(os.Stderr, "Error: invalid image hash %q (expected 64 hex digits)\n", h)
+			return 1
+		}
+	}
+
+	ctx := context.Background()
+	if *deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *deadline)
+		defer cancel()
+	}
+
+	// Two directories compare the documents they have in common; more than
+	// two documents are each compared against the first
+	batch := len(args) == 2 && isDir(args[0]) && isDir(args[1])
+	multi := len(args) > 2
+	if (batch || multi) && *format == "gitlab" {
+		fmt.Fprintf(os.Stderr, "Error: --format gitlab is not supported when comparing directories or more than two documents\n")
+		return 1
+	}
+	if (batch || multi) && tmpl != nil {
+		fmt.Fprintf(os.Stderr, "Error: --report-template is not supported when comparing directories or more than two documents\n")
+		return 1
+	}
+	if *watch && (batch || multi) {
+		fmt.Fprintf(os.Stderr, "Error: --watch is not supported when comparing directories or more than two documents\n")
+		return 1
+	}
+	if *watch && *deadline > 0 {
+		fmt.Fprintf(os.Stderr, "Error: --watch cannot be combined with --deadline\n")
+		return 1
+	}
+	if multi && *label2 != "" {
+		fmt.Fprintf(os.Stderr, "Error: --label2 cannot be used with more than two documents\n")
+		return 1
 	}
 
-	if err := diff.CheckDependencies(); err != nil {
+	var files []string
+	if !batch {
+		sourceOpts := source.Options{Timeout: *timeout, Context: ctx}
+		for _, arg := range args {
+			if multi && isDir(arg) {
+				fmt.Fprintf(os.Stderr, "Error: %s is a directory; directories can only be compared as a pair\n", arg)
+				return 1
+			}
+			doc, err := source.Resolve(arg, sourceOpts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+			defer doc.CleanupFn()
+			files = append(files, doc.Path)
+		}
+
+		for _, file := range files[1:] {
+			if err := validateInputFiles(files[0], file, *forbidSameFile, *followSymlinks && !*noFollowSymlinks); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+		}
+	}
+	var file1, file2 string
+	if len(files) == 2 {
+		file1, file2 = files[0], files[1]
+	}
+
+	// --watch follows the inputs that are local files; git revisions, URLs
+	// and the empty document cannot change
+	var watched []string
+	if *watch {
+		for i, file := range files {
+			if file == args[i] && file != source.NullArg {
+				watched = append(watched, file)
+			}
+		}
+		if len(watched) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: --watch needs at least one local input file\n")
+			return 1
+		}
+	}
+
+	converterName, err := markdown.SelectConverter(*converter)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
-	if err := runDiff(file1, file2, *verbose, *convertPNG); err != nil {
+	// Without ImageMagick, images are compared in Go unless an option needs it
+	needsMagick := *stripMetadata || *maxImageDimension > 0 || len(metricNames) > 0
+	if !*noMagick && !needsMagick {
+		if _, err := exec.LookPath("magick"); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ImageMagick not found, comparing only PNG, JPEG and GIF images without it\n")
+			*noMagick = true
+		}
+	}
+	if *noMagick {
+		if needsMagick {
+			fmt.Fprintf(os.Stderr, "Error: --no-magick cannot be combined with --strip-metadata, --max-image-dimension or metrics other than psnr\n")
+			return 1
+		}
+		*convertPNG = false
+		if *diffImageFormat != "png" {
+			fmt.Fprintf(os.Stderr, "Warning: --no-magick writes png diff images, ignoring --diff-image-format %s\n", *diffImageFormat)
+			*diffImageFormat = "png"
+		}
+	}
+
+	if err := diff.CheckDependencies(converterName, !*noDelta, !*noMagick); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return 1
+	}
+
+	if *diffImageFormat != "png" && !image.SupportsFormat(*diffImageFormat) {
+		fmt.Fprintf(os.Stderr, "Warning: ImageMagick cannot write %s, falling back to png\n", *diffImageFormat)
+		*diffImageFormat = "png"
+	}
+
+	opts := ddx.Options{
+		File1:         file1,
+		File2:         file2,
+		OutputDir:     *outputDir,
+		Label1:        *label1,
+		Label2:        *label2,
+		MarkdownDir:   *mdDir,
+		KeepRendered:  *keepRendered,
+		Gitignore:     *gitignore,
+		NoCleanup:     *noCleanup,
+		ConvertPNG:    *convertPNG,
+		StripMetadata: *stripMetadata,
+		DiffFormat:    *diffImageFormat,
+		DiffImageName: *diffImageName,
+		TempSuffix:    *diffTempSuffix,
+		Converter:     *converter,
+		Encoding:      *encoding,
+		Retries:       *retries,
+		Since:         sinceTime,
+		MaxDimension:  *maxImageDimension,
+		NoMagick:      *noMagick,
+		MaxImages:     *maxImages,
+		SortBy:        *sortBy,
+		Direction:     *direction,
+		DiffAlgorithm: *diffAlgorithm,
+		TextFormat:    *diffFormat,
+		Metrics:       metricNames,
+
+		Fuzz:              *fuzz,
+		CrossFormat:       *crossFormat,
+		LosslessThreshold: *losslessThreshold,
+		LossyThreshold:    *lossyThreshold,
+
+		NormalizeUnicode: *normalizeUnicode,
+		Sanitize:         *sanitize,
+		IgnoreCase:       *ignoreCase,
+		IgnoreWhitespace: *ignoreWhitespace,
+		PreFilters:       preFilters,
+		SharedImageNames: *sharedImageNames,
+		Section:          *section,
+		TableDiff:        *tableDiff,
+		DetectMoves:      *detectMoves,
+		StyleDiff:        *styleDiff,
+		IncludeHeaders:   *includeHeaders,
+		IncludeFooters:   *includeFooters,
+		IncludeFootnotes: *includeFootnotes,
+		FrontMatter:      *frontMatter,
+		FenceLang:        *fenceLang,
+
+		IncludeUnchangedImages: *includeUnchanged,
+		OnlyChangedImages:      *onlyChanged,
+		Flatten:                *flatten,
+		Manifest:               *manifest,
+		VerifyManifest:         *verifyManifest,
+		KeepIdenticalDiffs:     *keepIdenticalDiffs,
+		IgnoreImageHashes:      ignoreHashes,
+		IgnoreAlt:              ignoreAlt,
+		ChangedSectionsOnly:    *changedSectionsOnly,
+		ImageRange:             *imageRange,
+		MediaPrefixes:          mediaPrefixes,
+
+		TextWeight:  *textWeight,
+		ImageWeight: *imageWeight,
+	}
+	if *verbose {
+		opts.Debugf = func(format string, args ...any) {
+			fmt.Fprintf(os.Stderr, "\ndebug: "+format+"\n", args...)
+		}
+	}
+
+	if *noCleanup {
+		fmt.Fprintf(os.Stderr, "Warning: --no-cleanup leaves the extracted documents in %s; remove the ddx-* directories there when done\n", os.TempDir())
+	}
+
+	batchOpts := batchOptions{
+		parallel: *parallelFiles,
+		format:   *format,
+		exitCode: *exitCode,
+		failOn:   *failOn,
+		strict:   *strict,
+	}
+	if batch {
+		return runBatch(ctx, args[0], args[1], opts, batchOpts)
+	}
+	if multi {
+		return runMulti(ctx, files, args, opts, batchOpts)
+	}
+
+	var outputs []string // written below diff/imgs/ by the last comparison, for --watch
+	compareOnce := func() int {
+		bar := progress.New(ddx.Steps)
+		opts.Progress = barProgress(bar)
+		result, err := ddx.RunContext(ctx, opts)
+		if errors.Is(err, context.DeadlineExceeded) {
+			bar.Abort(fmt.Sprintf("deadline of %s exceeded", *deadline))
+		} else {
+			bar.Done()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			if errors.Is(err, ddx.ErrTooManyImages) {
+				fmt.Fprintln(os.Stderr, "Raise --max-images if the document is trusted, or leave images out with --since or --ignore-image-hash.")
+			}
+			return 1
+		}
+		outputs = outputPaths(result)
+
+		if *verbose {
+			for _, w := range result.Warnings {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+			}
+		}
+		for _, dir := range result.TempDirs {
+			fmt.Fprintf(os.Stderr, "Kept extracted files: %s\n", dir)
+		}
+		if *verbose || *timing {
+			fmt.Fprintf(os.Stderr, "Timing: %s\n", ddx.FormatTimings(result.Timings))
+		}
+
+		switch *format {
+		case "json":
+			if err := writeJSON(result.Report()); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+		case "gitlab":
+			if err := writeJSON(result.GitLabNotes()); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+		default:
+			display := displayOptions{
+				verbose:        *verbose,
+				summaryOnly:    *summaryOnly,
+				noDelta:        *noDelta,
+				deltaArgs:      deltaArgs,
+				forward:        *direction == ddx.DirectionForward,
+				precomputed:    *direction == ddx.DirectionForward || *diffAlgorithm != diff.AlgorithmMyers || *ignoreCase || *ignoreWhitespace,
+				verifyManifest: *verifyManifest,
+			}
+			if err := showResult(result, display); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+		}
+
+		if tmpl != nil {
+			path := *reportOutput
+			if path == "" {
+				path = filepath.Join(result.OutputDir, "report"+tmpl.Ext())
+			}
+			if err := tmpl.WriteFile(path, result); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+			if *format == "text" {
+				fmt.Printf("  %s\n", path)
+			}
+		}
+
+		if *strict && reportIncomplete("", result) {
+			return 1
+		}
+		if *exitCode && shouldFail(*failOn, result) {
+			return 1
+		}
+		if len(result.Drift) > 0 {
+			return 1
+		}
+		return 0
+	}
+	if *watch {
+		return watchInputs(watched, compareOnce, func() []string { return outputs })
+	}
+	return compareOnce()
+}
+
+// reportIncomplete prints to stderr what a --strict comparison left out,
+// prefixed by name when it is set, and reports whether there was anything
+func reportIncomplete(name string, result *ddx.Result) bool {
+	problems := result.Incomplete()
+	if len(problems) == 0 {
+		return false
+	}
+	prefix := ""
+	if name != "" {
+		prefix = name + ": "
+	}
+	fmt.Fprintf(os.Stderr, "Error: %sthe comparison is incomplete (--strict):\n", prefix)
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "  - %s\n", p)
+	}
+	return true
+}
+
+// outputFormats lists the values accepted by --format
+var outputFormats = []string{"text", "json", "gitlab"}
+
+// writeJSON prints v to stdout as indented JSON.
+func writeJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// barProgress returns a ddx.Options.Progress callback that renders bar.
+func barProgress(bar *progress.Bar) func(stage string, current, total int) {
+	step := ""
+	return func(stage string, current, total int) {
+		if stage == ddx.StageImages {
+			bar.Sub(current, total, step)
+			return
+		}
+		step = stage
+		bar.Set(current, stage)
+	}
+}
+
+// stringList is a flag.Value that collects every occurrence of a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// readHashList reads one hash per line from path, ignoring blank lines and
+// lines starting with '#'. Anything after the first field is treated as a
+// comment, so sha256sum output can be used directly.
+func readHashList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hash list: %w", err)
+	}
+	defer f.Close()
+
+	var hashes []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		hashes = append(hashes, fields[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read hash list: %w", err)
+	}
+	return hashes, nil
+}
+
+// readFilterFile reads one --pre-filter rule per line from path, ignoring
+// blank lines and lines starting with '#'. Rules are taken verbatim, so
+// they may contain spaces.
+func readFilterFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open filter file: %w", err)
+	}
+	defer f.Close()
+
+	var rules []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		rules = append(rules, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read filter file: %w", err)
+	}
+	return rules, nil
+}
+
+func validSHA256(h string) bool {
+	_, err := hex.DecodeString(h)
+	return err == nil && len(h) == 64
+}
+
+// parseMetrics parses the --metrics list. Only "psnr" means no extra
+// metrics, so nil is returned to keep the default output unchanged.
+func parseMetrics(value string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(image.Metrics, name) {
+			return nil, fmt.Errorf("invalid --metrics value %q (expected psnr, ssim, or ae)", name)
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 || slices.Equal(names, []string{"psnr"}) {
+		return nil, nil
+	}
+	return names, nil
+}
+
+// parseSince parses the --since value. An empty value means no cutoff.
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since value %q (expected RFC 3339 or YYYY-MM-DD)", value)
+}
+
+func validFailOn(failOn string) bool {
+	switch failOn {
+	case "text", "images", "any":
+		return true
+	}
+	return false
+}
+
+// shouldFail reports whether the differences found match the --fail-on scope.
+func shouldFail(failOn string, result *ddx.Result) bool {
+	switch failOn {
+	case "text":
+		return result.TextChanged || result.StyleDiff != ""
+	case "images":
+		return result.ImagesChanged()
+	default:
+		return result.TextChanged || result.StyleDiff != "" || result.ImagesChanged()
+	}
+}
+
+// printImageHashes lists the content hash of every compared image in
+// sha256sum format, so boilerplate images can be collected for
+// --ignore-image-hashes-file.
+func printImageHashes(result *image.MatchResult) {
+	var images []image.ImageInfo
+	for _, pair := range result.Matched {
+		images = append(images, pair.Image1, pair.Image2)
+	}
+	for _, pair := range result.Different {
+		images = append(images, pair.Image1, pair.Image2)
+	}
+	images = append(images, result.OnlyIn1...)
+	images = append(images, result.OnlyIn2...)
+	images = append(images, result.Skipped...)
+
+	fmt.Println("  SHA-256:")
+	seen := make(map[string]bool)
+	for _, img := range images {
+		if img.SHA256 == "" || seen[img.SHA256] {
+			continue
+		}
+		seen[img.SHA256] = true
+		fmt.Printf("    %s  %s\n", img.SHA256, img.Name)
 	}
 }
 
@@ -60,208 +804,433 @@ func printUsage() {
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  ddx [options] <file1.docx> <file2.docx>")
+	fmt.Println("  ddx --empty-baseline [options] <file.docx>")
+	fmt.Println("  ddx [options] <baseline.docx> <variant.docx>...")
+	fmt.Println("  ddx [options] <dir1> <dir2>")
+	fmt.Println("  ddx images [options] <dir1> <dir2>")
+	fmt.Println("  ddx clean [--force] [--dry-run] [--output-dir <dir>]")
+	fmt.Println("  ddx doctor")
+	fmt.Println()
+	fmt.Println("  Arguments of the form <rev>:<path> are read from git (git show <rev>:<path>).")
+	fmt.Println("  http(s):// arguments are downloaded to a temporary file first.")
+	fmt.Println("  /dev/null stands for an empty document.")
+	fmt.Println("  Two directories compare the .docx files with the same name in both, each into")
+	fmt.Println("  <output-dir>/<name>/.")
+	fmt.Println("  More than two documents are each compared against the first, into")
+	fmt.Println("  <output-dir>/<name>/, followed by a combined summary.")
 	fmt.Println()
 	fmt.Println("Options:")
 	fmt.Println("  -h, --help          Show this help message")
 	fmt.Println("  -v, --version       Show version")
 	fmt.Println("  --verbose           Show verbose output")
+	fmt.Println("  --timing            Print the time each stage took, e.g. extract 0.3s, convert 12.1s, match 48.7s")
+	fmt.Println("  --no-delta          Show the markdown diff with plain diff -u even when delta is installed")
+	fmt.Println("  --theme <name>      delta syntax theme, e.g. Nord, regardless of gitconfig (ignored without delta)")
+	fmt.Println("  --delta-arg <opt>   Extra option passed to delta, e.g. --side-by-side or --syntax-theme=Nord (repeatable)")
+	fmt.Println("  --summary-only      Print only aggregate image counts instead of one line per image")
+	fmt.Println("  --output-dir <dir>  Directory for diff output (default: diff)")
+	fmt.Println("  --gitignore         Write <output-dir>/.gitignore ignoring everything but diff.md")
+	fmt.Println("  --parallel-files <n>")
+	fmt.Println("                      Documents compared at once for directories or more than two documents (default: 1)")
+	fmt.Println("  --empty-baseline    Compare a single document against an empty one (same as /dev/null as <file1>)")
+	fmt.Println("  --label1 <name>     Name for the first document in output paths and the diff (default: file name)")
+	fmt.Println("  --label2 <name>     Name for the second document in output paths and the diff (default: file name)")
+	fmt.Println("  --md-dir <dir>      Directory for the per-document <docx>.md files (default: the output directory)")
+	fmt.Println("  --keep-rendered     Save both markdown files to the output directory with image links into diff/imgs/")
 	fmt.Println("  --convert-png       Convert vector images (wmf/emf/svg) to PNG before comparison (default: true)")
 	fmt.Println("                      Use --convert-png=false to disable and require LibreOffice instead")
+	fmt.Println("  --strip-metadata    Auto-orient and strip EXIF/metadata from raster images before comparison")
+	fmt.Println("  --normalize-unicode NFC-normalize markdown before diffing")
+	fmt.Println("  --sanitize          Show control characters such as form feeds as visible symbols (␌) before diffing")
+	fmt.Println("  --shared-image-names")
+	fmt.Println("                      Refer to images identical in both documents as shared/imageN.ext in the diff")
+	fmt.Println("  --include-unchanged-images")
+	fmt.Println("                      Also copy originals of unchanged images to diff/imgs/original/")
+	fmt.Println("  --no-cleanup        Debug: keep the extracted documents in temp directories and print the paths")
+	fmt.Println("  --keep-identical-diffs")
+	fmt.Println("                      Debug: keep heatmaps of identical pairs as <name1>-<name2>.identical.<ext>")
+	fmt.Println("  --manifest          Write diff/imgs/manifest.json with the SHA-256 of each diff image")
+	fmt.Println("  --verify-manifest   Report diff images that differ from diff/imgs/manifest.json; exit 1 on drift")
+	fmt.Println("  --only-changed-images")
+	fmt.Println("                      Copy originals of changed pairs only, not of added or removed images")
+	fmt.Println("  --flatten           Copy originals to diff/imgs/<docx>__<name>, next to the diff images,")
+	fmt.Println("                      instead of diff/imgs/original/<docx>/")
+	fmt.Println("  --detect-moves      Report blocks moved without changes (## Moved Sections in diff.md)")
+	fmt.Println("  --style-diff        Also diff paragraph styles and run formatting (## Style Changes in diff.md)")
+	fmt.Println("  --section <title>   Diff only the markdown under the heading <title>, up to the next")
+	fmt.Println("                      heading of the same or higher level (images are still compared in full)")
+	fmt.Println("  --include-headers   Diff the text of page headers (## Headers)")
+	fmt.Println("  --include-footers   Diff the text of page footers (## Footers)")
+	fmt.Println("  --include-footnotes Diff the footnotes (## Footnotes)")
+	fmt.Println("  --table-diff        Append cell-level table changes to diff.md (## Table Changes)")
+	fmt.Println("  --diff-format <fmt> Layout of diff.md: unified (fenced diff, default), side-by-side (GFM table),")
+	fmt.Println("                      or patch (unified plus diff.patch, applicable with patch -p1)")
+	fmt.Println("  --fence-lang <lang> Info string of the code fence in diff.md: diff, text, \"\" or none (default: diff)")
+	fmt.Println("  --front-matter      Prepend YAML front matter (files, timestamp, counts, similarity) to diff.md")
+	fmt.Println("  --forbid-same-file  Fail instead of warning when both inputs are the same file")
+	fmt.Println("  --follow-symlinks   Compare symlinked inputs by their targets (default: true)")
+	fmt.Println("  --no-follow-symlinks")
+	fmt.Println("                      Compare symlinked inputs as the links themselves")
+	fmt.Println("  --diff-image-format <fmt>")
+	fmt.Println("                      Format of generated diff images: png, webp, avif (default: png)")
+	fmt.Println("  --diff-image-name <template>")
+	fmt.Println("                      Name of diff images without extension (default: {name1}-{name2});")
+	fmt.Println("                      a name already used gets -2, -3, ... appended")
+	fmt.Println("  --diff-temp-suffix <suffix>")
+	fmt.Println("                      Suffix of the diff image magick compare writes before renaming (default: _cmp)")
+	fmt.Println("  --fuzz <percent>    Treat colors within this distance as equal in magick compare, e.g. 2 (default: 0)")
+	fmt.Println("  --no-magick         Compare PNG, JPEG and GIF images in Go instead of with ImageMagick and")
+	fmt.Println("                      skip other formats; used automatically when magick is not installed")
+	fmt.Println("  --cross-format      Match images left in one document against identical images of another")
+	fmt.Println("                      raster format, e.g. a figure re-exported from PNG to JPEG")
+	fmt.Println("  --psnr-threshold-lossless <db>")
+	fmt.Println("                      PSNR below which PNG/BMP/GIF/TIFF/vector pairs count as different (default: 1)")
+	fmt.Println("  --psnr-threshold-lossy <db>")
+	fmt.Println("                      PSNR below which JPEG/WebP pairs count as different (default: 0.5)")
+	fmt.Println("  --metrics <list>    Metrics to report for changed images: psnr, ssim, ae (default: psnr)")
+	fmt.Println("  --show-pixel-count  Report the number of changed pixels, e.g. (42,318 px changed)")
+	fmt.Println("  --sort-by <key>     Order changed images by psnr (most different first) or name")
+	fmt.Println("  --ignore-case       Treat text lines that differ only in letter case as unchanged")
+	fmt.Println("  --ignore-whitespace Treat text lines that differ only in white space as unchanged")
+	fmt.Println("  --pre-filter <regex>=<replacement>")
+	fmt.Println("                      Replace regex matches in both markdown texts before diffing, e.g. to mask")
+	fmt.Println("                      generated dates (repeatable; the rule splits at its last '=')")
+	fmt.Println("  --pre-filter-file <file>")
+	fmt.Println("                      File listing --pre-filter rules, one per line ('#' starts a comment line)")
+	fmt.Println("  --diff-algorithm <name>")
+	fmt.Println("                      Text diff algorithm: myers (diff -u, default), patience or histogram")
+	fmt.Println("  --direction <dir>   Changes to report: both (default), or forward for only removals")
+	fmt.Println("                      and modifications; additions are counted but not reported")
+	fmt.Println("  --json              Print a JSON report to stdout instead of the diff view and summary")
+	fmt.Println("  --format <fmt>      Output format on stdout: text, json, gitlab (default: text)")
+	fmt.Println("                      gitlab prints merge-request notes, one per changed section or image")
+	fmt.Println("  --report-template <name|file>")
+	fmt.Println("                      Also render a report with a Go text/template file, or the built-in")
+	fmt.Println("                      markdown or html template")
+	fmt.Println("  --report-output <path>")
+	fmt.Println("                      Path of the rendered report (default: <output-dir>/report.<ext>)")
+	fmt.Println("  --text-weight <w>   Weight of text similarity in the overall score (default: 1)")
+	fmt.Println("  --image-weight <w>  Weight of image similarity in the overall score (default: 1)")
+	fmt.Println("  --converter <name>  Docx-to-markdown converter: auto, markitdown, pandoc (default: auto,")
+	fmt.Println("                      which prefers markitdown and falls back to pandoc)")
+	fmt.Println("  --encoding <name>   Encoding of the converter output: utf-8, shift_jis, euc-jp, ... (default: utf-8)")
+	fmt.Println("  --retries <n>       Retries for transient markitdown/magick failures (default: 1)")
+	fmt.Println("  --timeout <dur>     Download timeout for http(s) URL arguments, e.g. 30s or 2m (default: 1m)")
+	fmt.Println("  --watch             Compare again whenever a local input file changes, until interrupted")
+	fmt.Println("  --deadline <dur>    Abort the whole comparison after this long, killing running tools (default: no limit)")
+	fmt.Println("  --since <time>      Only compare images modified (zip mtime) at or after <time>; older ones are skipped")
+	fmt.Printf("  --max-images <n>    Abort when a document has more than <n> images (default: %d, 0: no limit)\n", ddx.DefaultMaxImages)
+	fmt.Println("  --max-image-dimension <px>")
+	fmt.Println("                      Downscale both images of a pair to fit <px> before comparison (default: no limit)")
+	fmt.Println("  --media-prefix <prefix>")
+	fmt.Println("                      Also treat archive entries below <prefix> (e.g. word/media2/) as media (repeatable)")
+	fmt.Println("  --ignore-image-hash <sha256>")
+	fmt.Println("                      Leave images with this content hash out of the comparison (repeatable)")
+	fmt.Println("  --ignore-image-hashes-file <file>")
+	fmt.Println("                      Read hashes to ignore from <file>, one per line (sha256sum output works)")
+	fmt.Println("  --ignore-alt <text> Skip images whose alt text contains <text>, ignoring case (repeatable)")
+	fmt.Println("  --image-range <start>:<end>")
+	fmt.Println("                      Compare only the media at these positions of each document in natural")
+	fmt.Println("                      (numeric) name order, counted from 1, e.g. 10:20, 10: or :20; the rest")
+	fmt.Println("                      is skipped")
+	fmt.Println("  --changed-sections-only")
+	fmt.Println("                      Compare only the images referenced in heading sections whose text changed")
+	fmt.Println("  --exit-code         Exit with status 1 when differences are found")
+	fmt.Println("  --strict            Exit with status 1 when any image is skipped or a warning is reported,")
+	fmt.Println("                      listing what was left out and why")
+	fmt.Println("  --fail-on <scope>   Differences that count for --exit-code: text, images, any (default: any)")
 	fmt.Println()
 	fmt.Println("Output:")
 	fmt.Println("  diff/diff.md                        Markdown diff (unified format)")
-	fmt.Println("  diff/imgs/<name1>-<name2>.<ext>     Image diff (magick compare)")
+	fmt.Println("  diff/imgs/<name1>-<name2>.<fmt>     Image diff (magick compare)")
 	fmt.Println("  diff/imgs/original/<docx>/          Changed original images")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  ddx before.docx after.docx")
+	fmt.Println("  ddx HEAD~1:report.docx HEAD:report.docx")
 	fmt.Println()
 	fmt.Println("Requirements:")
-	fmt.Println("  - markitdown (https://github.com/microsoft/markitdown)")
+	fmt.Println("  - markitdown (https://github.com/microsoft/markitdown) or pandoc (https://pandoc.org)")
 	fmt.Println("  - delta (https://github.com/dandavison/delta)")
-	fmt.Println("  - ImageMagick (magick command)")
+	fmt.Println("  - ImageMagick (magick command); without it only PNG, JPEG and GIF images are compared")
 }
 
-func validateInputFiles(file1, file2 string) error {
+// validateInputFiles checks that both inputs are existing .docx files. A
+// symlink whose target is missing is always an error; followSymlinks decides
+// whether the same-file check looks at link targets or at the links themselves.
+func validateInputFiles(file1, file2 string, forbidSameFile, followSymlinks bool) error {
 	for _, f := range []string{file1, file2} {
 		if !strings.HasSuffix(strings.ToLower(f), ".docx") {
 			return fmt.Errorf("file %s is not a .docx file", f)
 		}
-		if _, err := os.Stat(f); os.IsNotExist(err) {
+		info, err := os.Lstat(f)
+		if os.IsNotExist(err) {
 			return fmt.Errorf("file %s does not exist", f)
 		}
-	}
-	return nil
-}
-
-func docxBaseName(path string) string {
-	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
-}
-
-func runDiff(file1, file2 string, verbose, convertPNG bool) error {
-	doc1Base := docxBaseName(file1)
-	doc2Base := docxBaseName(file2)
-
-	bar := progress.New(7)
-
-	// 1. Extract docx files to temp directories
-	bar.Advance("Extracting " + filepath.Base(file1) + "...")
-	extract1, err := docx.Extract(file1)
-	if err != nil {
-		bar.Done()
-		return fmt.Errorf("failed to extract %s: %w", file1, err)
-	}
-	defer extract1.CleanupFn()
-
-	bar.Advance("Extracting " + filepath.Base(file2) + "...")
-	extract2, err := docx.Extract(file2)
-	if err != nil {
-		bar.Done()
-		return fmt.Errorf("failed to extract %s: %w", file2, err)
-	}
-	defer extract2.CleanupFn()
-
-	// 2. Create output directory structure
-	diffImgsDir := filepath.Join("diff", "imgs")
-	orig1Dir := filepath.Join("diff", "imgs", "original", doc1Base)
-	orig2Dir := filepath.Join("diff", "imgs", "original", doc2Base)
-
-	for _, dir := range []string{diffImgsDir, orig1Dir, orig2Dir} {
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			bar.Done()
-			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if _, err := os.Stat(f); err != nil {
+				return fmt.Errorf("file %s is a symlink whose target is missing", f)
+			}
 		}
 	}
 
-	// 3. Convert to markdown and save alongside docx
-	bar.Advance("Converting " + filepath.Base(file1) + " to markdown...")
-	md1, err := markdown.ProcessMarkdown(file1, extract1.Images, extract1.TempDir)
-	if err != nil {
-		bar.Done()
-		return fmt.Errorf("failed to process %s: %w", file1, err)
+	stat := os.Stat
+	if !followSymlinks {
+		stat = os.Lstat
 	}
-
-	bar.Advance("Converting " + filepath.Base(file2) + " to markdown...")
-	md2, err := markdown.ProcessMarkdown(file2, extract2.Images, extract2.TempDir)
-	if err != nil {
-		bar.Done()
-		return fmt.Errorf("failed to process %s: %w", file2, err)
+	if sameFile(file1, file2, stat) {
+		if forbidSameFile {
+			return fmt.Errorf("%s and %s are the same file", file1, file2)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s and %s are the same file\n", file1, file2)
 	}
+	return nil
+}
 
-	// 4. Image matching
-	bar.Advance("Matching images...")
-	matchResult, err := image.MatchImageSets(extract1.Images, extract2.Images, diffImgsDir, convertPNG)
-	if err != nil {
-		bar.Done()
-		return fmt.Errorf("failed to match images: %w", err)
+// sameFile reports whether two paths refer to the same file, either by their
+// cleaned absolute paths or by device and inode.
+func sameFile(file1, file2 string, stat func(string) (os.FileInfo, error)) bool {
+	abs1, err1 := filepath.Abs(file1)
+	abs2, err2 := filepath.Abs(file2)
+	if err1 == nil && err2 == nil && abs1 == abs2 {
+		return true
 	}
 
-	// 5. Copy original images for changed pairs
-	bar.Advance("Copying original images...")
-	if err := copyOriginalImages(matchResult, orig1Dir, orig2Dir); err != nil {
-		bar.Done()
-		return fmt.Errorf("failed to copy original images: %w", err)
+	info1, err1 := stat(file1)
+	info2, err2 := stat(file2)
+	if err1 != nil || err2 != nil {
+		return false
 	}
+	return os.SameFile(info1, info2)
+}
 
-	// 6. Generate diff/diff.md with normalized image paths
-	bar.Advance("Generating diff.md...")
-	map1, map2 := markdown.BuildPathMapping(matchResult, doc1Base, doc2Base)
-	norm1 := markdown.NormalizeForDiff(md1.Content, map1)
-	norm2 := markdown.NormalizeForDiff(md2.Content, map2)
+// displayOptions controls how a result is printed to the terminal
+type displayOptions struct {
+	verbose        bool
+	summaryOnly    bool
+	noDelta        bool     // use plain diff -u even when delta is installed
+	deltaArgs      []string // extra delta options (--delta-arg)
+	forward        bool     // show only removals and modifications (--direction forward)
+	precomputed    bool     // show result.Diff instead of letting the viewer diff the files
+	verifyManifest bool     // report diff image drift from the manifest
+}
 
-	// Write normalized markdown to temp files for diff
+// showResult displays the markdown diff and prints the image summary and
+// output locations for a completed run.
+func showResult(result *ddx.Result, display displayOptions) error {
+	// Write normalized markdown to temp files for the diff viewer
 	tmpDir, err := os.MkdirTemp("", "ddx-normdiff-*")
 	if err != nil {
-		bar.Done()
 		return fmt.Errorf("failed to create temp directory: %w", err)
 	}
 	defer os.RemoveAll(tmpDir)
 
-	normPath1 := filepath.Join(tmpDir, doc1Base+".md")
-	normPath2 := filepath.Join(tmpDir, doc2Base+".md")
+	normPath1 := filepath.Join(tmpDir, result.Doc1Base+".md")
+	normPath2 := filepath.Join(tmpDir, result.Doc2Base+".md")
 
-	if err := os.WriteFile(normPath1, []byte(norm1), 0644); err != nil {
-		bar.Done()
+	if err := os.WriteFile(normPath1, []byte(result.Normalized1), 0644); err != nil {
 		return err
 	}
-	if err := os.WriteFile(normPath2, []byte(norm2), 0644); err != nil {
-		bar.Done()
+	if err := os.WriteFile(normPath2, []byte(result.Normalized2), 0644); err != nil {
 		return err
 	}
 
-	if err := diff.GenerateDiffFile(normPath1, normPath2, filepath.Join("diff", "diff.md")); err != nil {
-		bar.Done()
-		return fmt.Errorf("failed to generate diff.md: %w", err)
+	// Display diff via delta
+	fmt.Println("=== Markdown Diff ===")
+	fmt.Println()
+	if display.precomputed {
+		// The viewers diff the files themselves, so show the computed diff instead
+		if err := diff.ShowUnified(result.Diff, !display.noDelta, display.deltaArgs); err != nil {
+			return fmt.Errorf("failed to show diff: %w", err)
+		}
+	} else {
+		var err error
+		if display.noDelta {
+			err = diff.ShowStandardDiff(normPath1, normPath2)
+		} else {
+			err = diff.ShowDiffWithFallback(normPath1, normPath2, display.deltaArgs)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to show diff: %w", err)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("--- %s ---\n", result.DiffStat)
+	if display.forward && result.DiffStat.Insertions > 0 {
+		fmt.Println("  (additions are counted but not shown with --direction forward)")
+	}
+
+	if result.TableDiff != "" {
+		fmt.Println()
+		fmt.Println("=== Table Changes ===")
+		fmt.Println()
+		fmt.Print(strings.TrimPrefix(result.TableDiff, "## Table Changes\n\n"))
 	}
 
-	// 7. Display diff via delta
-	bar.Done()
+	if result.StyleDiff != "" {
+		fmt.Println()
+		fmt.Println("=== Style Changes ===")
+		fmt.Println()
+		if err := diff.ShowUnified(result.StyleDiff, !display.noDelta, display.deltaArgs); err != nil {
+			return fmt.Errorf("failed to show style changes: %w", err)
+		}
+	}
 
-	fmt.Println("=== Markdown Diff ===")
-	fmt.Println()
-	if err := diff.ShowDiffWithFallback(normPath1, normPath2); err != nil {
-		return fmt.Errorf("failed to show diff: %w", err)
+	if len(result.Moves) > 0 {
+		fmt.Println()
+		fmt.Println("=== Moved Sections ===")
+		fmt.Println()
+		fmt.Print(strings.TrimPrefix(markdown.FormatMoves(result.Moves), "## Moved Sections\n\n"))
 	}
 
-	// 8. Print summary
+	// Print summary
 	fmt.Println()
 	fmt.Println("=== Image Comparison ===")
 	fmt.Println()
-	printMatchSummary(matchResult, verbose)
+	printMatchSummary(result.MatchResult, display)
+	if len(result.AddedImages) > 0 {
+		fmt.Printf("  %s added (not reported with --direction forward).\n", countImages(len(result.AddedImages)))
+	}
+	if media := result.MediaBytes(); media.Total > 0 {
+		fmt.Printf("  Changed media: %s of %s\n", ddx.FormatBytes(media.Changed), ddx.FormatBytes(media.Total))
+	}
+
+	if result.Macros.File1 || result.Macros.File2 {
+		fmt.Println()
+		fmt.Println("=== Macros ===")
+		fmt.Println()
+		printMacros(result)
+	}
+
+	if display.verifyManifest {
+		fmt.Println()
+		fmt.Println("=== Manifest ===")
+		fmt.Println()
+		printDrift(result.Drift)
+	}
+
+	fmt.Println()
+	fmt.Println("=== Similarity ===")
+	fmt.Println()
+	sim := result.Similarity
+	fmt.Printf("  Documents are %.1f%% similar (text %.1f%%, images %.1f%%)\n",
+		sim.Overall*100, sim.Text*100, sim.Images*100)
+	words := result.Words
+	fmt.Printf("  Words: %s -> %s (%+d), about %s -> %s min to read\n",
+		image.GroupThousands(int64(words.Words1)), image.GroupThousands(int64(words.Words2)), words.Delta(),
+		strconv.FormatFloat(words.ReadingMinutes1, 'f', -1, 64), strconv.FormatFloat(words.ReadingMinutes2, 'f', -1, 64))
 
 	fmt.Println()
 	fmt.Println("=== Output ===")
-	fmt.Printf("  diff/diff.md\n")
-	if len(matchResult.Different) > 0 {
-		fmt.Printf("  diff/imgs/ (%d diff images)\n", len(matchResult.Different))
-		fmt.Printf("  diff/imgs/original/%s/\n", doc1Base)
-		fmt.Printf("  diff/imgs/original/%s/\n", doc2Base)
+	fmt.Printf("  %s\n", result.DiffPath)
+	if result.PatchPath != "" {
+		fmt.Printf("  %s\n", result.PatchPath)
+	}
+	fmt.Printf("  %s\n", result.Markdown1)
+	fmt.Printf("  %s\n", result.Markdown2)
+	for _, rendered := range []string{result.Rendered1, result.Rendered2} {
+		if rendered != "" && rendered != result.Markdown1 && rendered != result.Markdown2 {
+			fmt.Printf("  %s\n", rendered)
+		}
+	}
+	imgsDir := filepath.Join(result.OutputDir, "imgs")
+	if len(result.MatchResult.Different) > 0 {
+		fmt.Printf("  %s/ (%d diff images)\n", imgsDir, len(result.MatchResult.Different))
+	}
+	for _, base := range []string{result.Doc1Base, result.Doc2Base} {
+		origDir := filepath.Join(imgsDir, "original", base)
+		if info, err := os.Stat(origDir); err == nil && info.IsDir() {
+			fmt.Printf("  %s/\n", origDir)
+		}
 	}
 
 	return nil
 }
 
-func copyOriginalImages(matchResult *image.MatchResult, orig1Dir, orig2Dir string) error {
-	// Copy originals for different pairs
-	for _, pair := range matchResult.Different {
-		dst1 := filepath.Join(orig1Dir, pair.Image1.Name)
-		if err := image.CopyFile(pair.Image1.Path, dst1); err != nil {
-			return fmt.Errorf("failed to copy %s: %w", pair.Image1.Name, err)
-		}
-		dst2 := filepath.Join(orig2Dir, pair.Image2.Name)
-		if err := image.CopyFile(pair.Image2.Path, dst2); err != nil {
-			return fmt.Errorf("failed to copy %s: %w", pair.Image2.Name, err)
-		}
+// printMacros flags the documents that contain VBA macros and whether the
+// revision added or removed them
+func printMacros(result *ddx.Result) {
+	name1, name2 := filepath.Base(result.File1), filepath.Base(result.File2)
+	if result.Macros.File1 {
+		fmt.Printf("  [WARN] %s contains macros (vbaProject.bin)\n", name1)
+	}
+	if result.Macros.File2 {
+		fmt.Printf("  [WARN] %s contains macros (vbaProject.bin)\n", name2)
 	}
+	switch {
+	case !result.Macros.File1:
+		fmt.Printf("  Macros were added in %s.\n", name2)
+	case !result.Macros.File2:
+		fmt.Printf("  Macros were removed in %s.\n", name2)
+	}
+}
 
-	// Copy originals for only-in-one
-	for _, img := range matchResult.OnlyIn1 {
-		dst := filepath.Join(orig1Dir, img.Name)
-		if err := image.CopyFile(img.Path, dst); err != nil {
-			return fmt.Errorf("failed to copy %s: %w", img.Name, err)
-		}
+// printDrift lists diff images whose content differs from the manifest
+func printDrift(drift []image.Drift) {
+	if len(drift) == 0 {
+		fmt.Println("  Diff images match the manifest.")
+		return
 	}
-	for _, img := range matchResult.OnlyIn2 {
-		dst := filepath.Join(orig2Dir, img.Name)
-		if err := image.CopyFile(img.Path, dst); err != nil {
-			return fmt.Errorf("failed to copy %s: %w", img.Name, err)
+	for _, d := range drift {
+		switch {
+		case d.Old == "":
+			fmt.Printf("  [NEW]     %s\n", d.Image)
+		case d.New == "":
+			fmt.Printf("  [MISSING] %s\n", d.Image)
+		default:
+			fmt.Printf("  [CHANGED] %s\n", d.Image)
 		}
 	}
+	fmt.Printf("  %d diff image(s) drifted from the manifest.\n", len(drift))
+}
 
-	return nil
+func countImages(n int) string {
+	if n == 1 {
+		return "1 image"
+	}
+	return fmt.Sprintf("%d images", n)
 }
 
-func printMatchSummary(result *image.MatchResult, verbose bool) {
+func printMatchSummary(result *image.MatchResult, display displayOptions) {
+	if display.summaryOnly {
+		fmt.Printf("  %d changed, %d added, %d removed, %d unchanged",
+			len(result.Different), len(result.OnlyIn2), len(result.OnlyIn1), len(result.Matched))
+		if len(result.Skipped) > 0 {
+			fmt.Printf(", %d skipped", len(result.Skipped))
+		}
+		if len(result.Broken) > 0 {
+			fmt.Printf(", %d broken", len(result.Broken))
+		}
+		fmt.Println()
+		return
+	}
+
+	verbose := display.verbose
 	if verbose {
 		for _, pair := range result.Matched {
-			fmt.Printf("  [SAME] %s <-> %s\n", pair.Image1.Name, pair.Image2.Name)
+			fmt.Printf("  [SAME] %s <-> %s", pair.Image1.Name, pair.Image2.Name)
+			if pair.PSNR >= 0 {
+				fmt.Printf(" (PSNR: %s)", image.FormatPSNR(pair.PSNR))
+			}
+			fmt.Println()
+			if pair.DiffPath != "" {
+				fmt.Printf("         -> %s\n", pair.DiffPath)
+			}
 		}
 	}
 
 	for _, pair := range result.Different {
 		fmt.Printf("  [DIFF] %s <-> %s", pair.Image1.Name, pair.Image2.Name)
-		if pair.PSNR >= 0 {
-			fmt.Printf(" (PSNR: %.3f)", pair.PSNR)
+		if len(pair.Metrics) > 0 {
+			fmt.Printf(" (%s)", image.FormatMetrics(pair.Metrics))
+		} else if pair.PSNR >= 0 {
+			fmt.Printf(" (PSNR: %s)", image.FormatPSNR(pair.PSNR))
+		}
+		if pair.Resized() {
+			fmt.Printf(" %s -> %s", pair.Dims1, pair.Dims2)
+			if !pair.Dims1.SameAspect(pair.Dims2) {
+				fmt.Print(", aspect ratio changed")
+			}
 		}
 		fmt.Println()
 		if verbose && pair.DiffPath != "" {
@@ -278,14 +1247,27 @@ func printMatchSummary(result *image.MatchResult, verbose bool) {
 
 	if len(result.Skipped) > 0 && verbose {
 		for _, img := range result.Skipped {
-			fmt.Printf("  [SKIP] %s\n", img.Name)
+			fmt.Printf("  [SKIP] %s (%s)\n", img.Name, img.Skip)
 		}
 	}
+	for _, img := range result.Broken {
+		fmt.Printf("  [WARN] %s is %s\n", img.Name, img.Skip)
+	}
+
+	if verbose {
+		printImageHashes(result)
+	}
 
 	total := len(result.Different) + len(result.OnlyIn1) + len(result.OnlyIn2)
-	if total == 0 {
+	paired := len(result.Matched) + len(result.Different)
+	switch {
+	case total == 0:
 		fmt.Println("  No image differences found.")
-	} else {
+	case paired == 0 && len(result.OnlyIn1) == 0:
+		fmt.Printf("  %s added, none in original.\n", countImages(len(result.OnlyIn2)))
+	case paired == 0 && len(result.OnlyIn2) == 0:
+		fmt.Printf("  %s removed, none in revised.\n", countImages(len(result.OnlyIn1)))
+	default:
 		fmt.Printf("  %d difference(s) found.\n", total)
 	}
 }
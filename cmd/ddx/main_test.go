@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/shioshosho/diff-docx/internal/image"
+)
+
+// captureStdout returns what f prints to stdout
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	f()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestPrintMatchSummaryOneSided(t *testing.T) {
+	three := []image.ImageInfo{{Name: "image1.png"}, {Name: "image2.png"}, {Name: "image3.png"}}
+	tests := []struct {
+		name   string
+		result *image.MatchResult
+		want   string
+	}{
+		{
+			name:   "all added",
+			result: &image.MatchResult{OnlyIn2: three},
+			want: "  [ADD]  image1.png (only in second document)\n" +
+				"  [ADD]  image2.png (only in second document)\n" +
+				"  [ADD]  image3.png (only in second document)\n" +
+				"  3 images added, none in original.\n",
+		},
+		{
+			name:   "all removed",
+			result: &image.MatchResult{OnlyIn1: three[:1]},
+			want: "  [DEL]  image1.png (only in first document)\n" +
+				"  1 image removed, none in revised.\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := captureStdout(t, func() { printMatchSummary(tt.result, displayOptions{}) })
+			if got != tt.want {
+				t.Errorf("printMatchSummary() printed\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shioshosho/diff-docx/pkg/ddx"
+)
+
+// watchInterval is how often --watch checks the inputs for changes
+const watchInterval = 500 * time.Millisecond
+
+// watchSettle is how long changed inputs must stay unchanged before
+// --watch compares again, so that a save made of several writes triggers
+// one comparison
+const watchSettle = time.Second
+
+// fileState is what --watch compares to notice that a file changed
+type fileState struct {
+	modTime time.Time
+	size    int64
+	missing bool
+}
+
+func statFiles(files []string) []fileState {
+	states := make([]fileState, len(files))
+	for i, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			states[i].missing = true
+			continue
+		}
+		states[i] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+	return states
+}
+
+// watchInputs runs compare, then runs it again whenever one of files
+// changes, until the process is interrupted. The files the previous run
+// wrote below diff/imgs/, as listed by previous, are removed first so that
+// none of them linger; the rest of diff/imgs/, such as the manifest, is
+// kept. Files are polled rather than watched with a notification API:
+// that keeps the module free of a platform-specific dependency, and two
+// stat calls per interval cost nothing next to a comparison. Polling also
+// follows editors that save by renaming a temporary file over the input,
+// and inputs on network drives, where notifications are unreliable.
+func watchInputs(files []string, compare func() int, previous func() []string) int {
+	compare()
+	fmt.Fprintf(os.Stderr, "Watching %s for changes (Ctrl-C to stop)...\n", strings.Join(files, ", "))
+
+	last := statFiles(files)
+	for {
+		time.Sleep(watchInterval)
+		current := statFiles(files)
+		if slices.Equal(current, last) {
+			continue
+		}
+		// Wait for the save to settle
+		for {
+			time.Sleep(watchSettle)
+			settled := statFiles(files)
+			if slices.Equal(settled, current) {
+				break
+			}
+			current = settled
+		}
+
+		var changed []string
+		for i := range files {
+			if current[i] != last[i] {
+				changed = append(changed, files[i])
+			}
+		}
+		last = current
+
+		fmt.Printf("\n===== %s: %s changed =====\n\n", time.Now().Format("2006-01-02 15:04:05"), strings.Join(changed, ", "))
+		removeOutputs(previous())
+		compare()
+	}
+}
+
+// removeOutputs removes the files of a previous run, then the directories
+// they leave empty, such as imgs/original/<docx>/ and imgs/original/
+func removeOutputs(paths []string) {
+	dirs := make(map[string]bool)
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Warning: failed to remove previous output: %v\n", err)
+		}
+		dirs[filepath.Dir(path)] = true
+	}
+	for dir := range dirs {
+		// Removing fails, as intended, for a directory that still holds files
+		for filepath.Base(dir) != "imgs" && os.Remove(dir) == nil {
+			dir = filepath.Dir(dir)
+		}
+	}
+}
+
+// outputPaths lists the files below diff/imgs/ a comparison wrote: the
+// diff images, including kept heatmaps of identical pairs, and the copied
+// originals
+func outputPaths(result *ddx.Result) []string {
+	var paths []string
+	for _, p := range result.MatchResult.Different {
+		if p.DiffPath != "" {
+			paths = append(paths, p.DiffPath)
+		}
+	}
+	for _, p := range result.MatchResult.Matched {
+		if p.DiffPath != "" {
+			paths = append(paths, p.DiffPath)
+		}
+	}
+	return append(paths, result.Originals...)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveOutputs(t *testing.T) {
+	imgs := filepath.Join(t.TempDir(), "imgs")
+	written := []string{
+		filepath.Join(imgs, "image1-image1.png"),
+		filepath.Join(imgs, "original", "draft", "image1.png"),
+		filepath.Join(imgs, "original", "revised", "sub", "image1.png"),
+		filepath.Join(imgs, "revised__image2.png"),
+	}
+	kept := filepath.Join(imgs, "manifest.json")
+	for _, path := range append(written, kept) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removeOutputs(written)
+	entries, err := os.ReadDir(imgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "manifest.json" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("imgs/ holds %q, want only manifest.json", names)
+	}
+}
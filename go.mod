@@ -3,6 +3,8 @@ module github.com/shioshosho/diff-docx
 go 1.24.0
 
 require (
-	golang.org/x/sys v0.40.0 // indirect
-	golang.org/x/term v0.39.0 // indirect
+	golang.org/x/term v0.39.0
+	golang.org/x/text v0.33.0
 )
+
+require golang.org/x/sys v0.40.0 // indirect
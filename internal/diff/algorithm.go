@@ -0,0 +1,385 @@
+package diff
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Diff algorithms for UnifiedWith
+const (
+	AlgorithmMyers     = "myers"     // diff -u, the default
+	AlgorithmPatience  = "patience"  // anchors on lines unique to both sides
+	AlgorithmHistogram = "histogram" // anchors on the rarest lines, like git's histogram diff
+)
+
+// Algorithms lists the accepted diff algorithms
+var Algorithms = []string{AlgorithmMyers, AlgorithmPatience, AlgorithmHistogram}
+
+// contextLines is the number of unchanged lines around each hunk, as diff -u
+const contextLines = 3
+
+// maxFallbackCells bounds the LCS table used when patience or histogram
+// finds no anchor; larger regions are reported as replaced wholesale
+const maxFallbackCells = 1 << 22
+
+// maxChain is the occurrence count above which histogram diff ignores a
+// line as an anchor candidate
+const maxChain = 64
+
+// Options controls UnifiedWith
+type Options struct {
+	Algorithm  string // AlgorithmMyers (default, also ""), AlgorithmPatience or AlgorithmHistogram
+	IgnoreCase bool   // lines that differ only in letter case compare equal, as diff -i
+
+	// IgnoreWhitespace makes lines that differ only in white space compare
+	// equal, as diff -w
+	IgnoreWhitespace bool
+}
+
+// key returns the form of line that is compared under opts
+func (o Options) key(line string) string {
+	if o.IgnoreCase {
+		line = strings.ToLower(line)
+	}
+	if o.IgnoreWhitespace {
+		line = strings.Join(strings.Fields(line), "")
+	}
+	return line
+}
+
+// UnifiedWith returns a unified diff of two files computed with
+// opts.Algorithm. Myers runs diff -u; patience and histogram are computed
+// in-process and produce the same format. Lines compared equal only through
+// opts, such as under IgnoreCase, are shown as in file1.
+func UnifiedWith(file1, file2 string, opts Options) (string, error) {
+	switch opts.Algorithm {
+	case "", AlgorithmMyers:
+		return unified(file1, file2, opts)
+	case AlgorithmPatience, AlgorithmHistogram:
+	default:
+		return "", fmt.Errorf("unknown diff algorithm %q", opts.Algorithm)
+	}
+
+	var lines [2][]string
+	var headers [2]string
+	for i, file := range []string{file1, file2} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("diff failed: %w", err)
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", fmt.Errorf("diff failed: %w", err)
+		}
+		lines[i] = splitLines(string(data))
+		headers[i] = file + "\t" + info.ModTime().Format("2006-01-02 15:04:05.000000000 -0700")
+	}
+
+	l := lineDiff{a: keys(lines[0], opts), b: keys(lines[1], opts)}
+	if opts.Algorithm == AlgorithmPatience {
+		l.patience(0, len(l.a), 0, len(l.b))
+	} else {
+		l.histogram(0, len(l.a), 0, len(l.b))
+	}
+	return formatUnified(lines[0], lines[1], l.edits(), headers[0], headers[1]), nil
+}
+
+// keys returns the compared form of each line
+func keys(lines []string, opts Options) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = opts.key(line)
+	}
+	return out
+}
+
+// splitLines splits text into lines that keep their "\n", so that a last
+// line without one differs from the same line with one, as in diff
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineMatch pairs equal lines a[ai] and b[bi]
+type lineMatch struct {
+	ai, bi int
+}
+
+// lineDiff collects the matching lines of a and b, which hold the compared
+// form of the lines
+type lineDiff struct {
+	a, b    []string
+	matches []lineMatch
+}
+
+// trim matches the common prefix and suffix of a[aLo:aHi] and b[bLo:bHi]
+// and returns the remaining ranges
+func (l *lineDiff) trim(aLo, aHi, bLo, bHi int) (int, int, int, int) {
+	for aLo < aHi && bLo < bHi && l.a[aLo] == l.b[bLo] {
+		l.matches = append(l.matches, lineMatch{aLo, bLo})
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && l.a[aHi-1] == l.b[bHi-1] {
+		aHi--
+		bHi--
+		l.matches = append(l.matches, lineMatch{aHi, bHi})
+	}
+	return aLo, aHi, bLo, bHi
+}
+
+// patience matches the lines that occur exactly once in both ranges along
+// their longest increasing sequence, then recurses between those anchors
+func (l *lineDiff) patience(aLo, aHi, bLo, bHi int) {
+	aLo, aHi, bLo, bHi = l.trim(aLo, aHi, bLo, bHi)
+	if aLo == aHi || bLo == bHi {
+		return
+	}
+
+	type occurrence struct {
+		countA, countB int
+		ai, bi         int
+	}
+	occurrences := make(map[string]*occurrence)
+	for i := aLo; i < aHi; i++ {
+		o := occurrences[l.a[i]]
+		if o == nil {
+			o = &occurrence{}
+			occurrences[l.a[i]] = o
+		}
+		o.countA++
+		o.ai = i
+	}
+	for j := bLo; j < bHi; j++ {
+		if o := occurrences[l.b[j]]; o != nil {
+			o.countB++
+			o.bi = j
+		}
+	}
+	var unique []lineMatch
+	for i := aLo; i < aHi; i++ {
+		if o := occurrences[l.a[i]]; o.countA == 1 && o.countB == 1 {
+			unique = append(unique, lineMatch{o.ai, o.bi})
+		}
+	}
+
+	anchors := longestIncreasing(unique)
+	if len(anchors) == 0 {
+		l.fallback(aLo, aHi, bLo, bHi)
+		return
+	}
+	for _, m := range anchors {
+		l.patience(aLo, m.ai, bLo, m.bi)
+		l.matches = append(l.matches, m)
+		aLo, bLo = m.ai+1, m.bi+1
+	}
+	l.patience(aLo, aHi, bLo, bHi)
+}
+
+// longestIncreasing returns the longest subsequence of matches, which are
+// ordered by ai, whose bi also increase (patience sorting)
+func longestIncreasing(matches []lineMatch) []lineMatch {
+	var tails []int // index into matches of the smallest tail of each length
+	prev := make([]int, len(matches))
+	for i, m := range matches {
+		k := sort.Search(len(tails), func(k int) bool { return matches[tails[k]].bi >= m.bi })
+		prev[i] = -1
+		if k > 0 {
+			prev[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	if len(tails) == 0 {
+		return nil
+	}
+	seq := make([]lineMatch, len(tails))
+	for i, k := len(tails)-1, tails[len(tails)-1]; i >= 0; i, k = i-1, prev[k] {
+		seq[i] = matches[k]
+	}
+	return seq
+}
+
+// histogram picks the longest common region around the rarest line of
+// a[aLo:aHi] that also occurs in b[bLo:bHi], matches it and recurses on both
+// sides of it
+func (l *lineDiff) histogram(aLo, aHi, bLo, bHi int) {
+	aLo, aHi, bLo, bHi = l.trim(aLo, aHi, bLo, bHi)
+	if aLo == aHi || bLo == bHi {
+		return
+	}
+
+	positions := make(map[string][]int)
+	for i := aLo; i < aHi; i++ {
+		positions[l.a[i]] = append(positions[l.a[i]], i)
+	}
+
+	bestCount := maxChain + 1
+	var bestA, bestB, bestLen int
+	for j := bLo; j < bHi; j++ {
+		occurrences := positions[l.b[j]]
+		if len(occurrences) == 0 || len(occurrences) > bestCount {
+			continue
+		}
+		for _, i := range occurrences {
+			// Extend the match in both directions within the ranges
+			sa, sb := i, j
+			for sa > aLo && sb > bLo && l.a[sa-1] == l.b[sb-1] {
+				sa--
+				sb--
+			}
+			ea, eb := i+1, j+1
+			for ea < aHi && eb < bHi && l.a[ea] == l.b[eb] {
+				ea++
+				eb++
+			}
+			if len(occurrences) < bestCount || ea-sa > bestLen {
+				bestCount, bestA, bestB, bestLen = len(occurrences), sa, sb, ea-sa
+			}
+		}
+	}
+
+	if bestLen == 0 {
+		l.fallback(aLo, aHi, bLo, bHi)
+		return
+	}
+	l.histogram(aLo, bestA, bLo, bestB)
+	for k := 0; k < bestLen; k++ {
+		l.matches = append(l.matches, lineMatch{bestA + k, bestB + k})
+	}
+	l.histogram(bestA+bestLen, aHi, bestB+bestLen, bHi)
+}
+
+// fallback matches a region without anchors by longest common subsequence,
+// or leaves it unmatched when the region is too large for that
+func (l *lineDiff) fallback(aLo, aHi, bLo, bHi int) {
+	n, m := aHi-aLo, bHi-bLo
+	if n*m > maxFallbackCells {
+		return
+	}
+	// lcs[i][j] is the LCS length of a[aLo+i:aHi] and b[bLo+j:bHi]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if l.a[aLo+i] == l.b[bLo+j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case l.a[aLo+i] == l.b[bLo+j]:
+			l.matches = append(l.matches, lineMatch{aLo + i, bLo + j})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+}
+
+// edit is one line of a unified diff body: ' ' keeps a[a], '-' removes
+// a[a] and '+' adds b[b]
+type edit struct {
+	op   byte
+	a, b int // line indexes, and the position in the other file for '-' and '+'
+}
+
+// edits turns the matches into an edit script, removals before additions
+func (l *lineDiff) edits() []edit {
+	sort.Slice(l.matches, func(i, j int) bool { return l.matches[i].ai < l.matches[j].ai })
+	var edits []edit
+	i, j := 0, 0
+	for _, m := range append(l.matches, lineMatch{len(l.a), len(l.b)}) {
+		for ; i < m.ai; i++ {
+			edits = append(edits, edit{'-', i, j})
+		}
+		for ; j < m.bi; j++ {
+			edits = append(edits, edit{'+', i, j})
+		}
+		if m.ai < len(l.a) {
+			edits = append(edits, edit{' ', i, j})
+			i++
+			j++
+		}
+	}
+	return edits
+}
+
+// formatUnified renders edits as a diff -u style unified diff; it is empty
+// when nothing changed
+func formatUnified(a, b []string, edits []edit, header1, header2 string) string {
+	var changes []int
+	for k, e := range edits {
+		if e.op != ' ' {
+			changes = append(changes, k)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("--- " + header1 + "\n")
+	sb.WriteString("+++ " + header2 + "\n")
+	for c := 0; c < len(changes); {
+		// Merge changes separated by at most twice the context into one hunk
+		last := c
+		for last+1 < len(changes) && changes[last+1]-changes[last]-1 <= 2*contextLines {
+			last++
+		}
+		start := max(changes[c]-contextLines, 0)
+		end := min(changes[last]+contextLines+1, len(edits))
+		c = last + 1
+
+		var aLines, bLines int
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				aLines++
+			}
+			if e.op != '-' {
+				bLines++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(edits[start].a, aLines), hunkRange(edits[start].b, bLines))
+		for _, e := range edits[start:end] {
+			var line string
+			if e.op == '+' {
+				line = b[e.b]
+			} else {
+				line = a[e.a]
+			}
+			sb.WriteString(string(e.op) + line)
+			if !strings.HasSuffix(line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// hunkRange formats the range of a hunk header for 0-based start
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,121 @@
+package diff
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePair writes a and b to files in a temporary directory
+func writePair(t *testing.T, a, b string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	file1, file2 := filepath.Join(dir, "a.md"), filepath.Join(dir, "b.md")
+	if err := os.WriteFile(file1, []byte(a), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file2, []byte(b), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file1, file2
+}
+
+// body drops the ---/+++ file headers of a unified diff, whose timestamps
+// are formatted differently by diff
+func body(unified string) string {
+	lines := strings.SplitAfterN(unified, "\n", 3)
+	if len(lines) < 3 {
+		return unified
+	}
+	return lines[2]
+}
+
+func TestUnifiedWithMatchesDiff(t *testing.T) {
+	if _, err := exec.LookPath("diff"); err != nil {
+		t.Skip("diff is not installed")
+	}
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"identical", "a\nb\nc\n", "a\nb\nc\n"},
+		{"changed line", "a\nb\nc\nd\ne\n", "a\nb\nC\nd\ne\n"},
+		{"inserted line", "a\nb\nc\nd\n", "a\nb\nnew\nc\nd\n"},
+		{"removed line", "a\nb\nc\nd\n", "a\nc\nd\n"},
+		{"first and last", "a\nb\nc\n", "A\nb\nC\n"},
+		{"two hunks", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n", "1\nTWO\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\nFOURTEEN\n15\n"},
+		{"empty to text", "", "a\nb\n"},
+		{"text to empty", "a\nb\n", ""},
+		{"no trailing newline in first", "a\nb", "a\nb\n"},
+		{"no trailing newline in second", "a\nb\n", "a\nb"},
+		{"no trailing newline in both", "a\nb", "a\nc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file1, file2 := writePair(t, tt.a, tt.b)
+			want, err := UnifiedWith(file1, file2, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, algorithm := range []string{AlgorithmPatience, AlgorithmHistogram} {
+				got, err := UnifiedWith(file1, file2, Options{Algorithm: algorithm})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body(got) != body(want) {
+					t.Errorf("%s diff =\n%s\ndiff -u =\n%s", algorithm, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestUnifiedWithRepeatedBoilerplate(t *testing.T) {
+	// Tables and separators repeat the same lines; a changed cell must not
+	// make the rest of the section read as replaced
+	var a, b strings.Builder
+	for i := range 20 {
+		a.WriteString("| --- | --- |\n| cell | cell |\n\n---\n\n")
+		b.WriteString("| --- | --- |\n")
+		if i == 10 {
+			b.WriteString("| cell | changed |\n")
+		} else {
+			b.WriteString("| cell | cell |\n")
+		}
+		b.WriteString("\n---\n\n")
+	}
+	file1, file2 := writePair(t, a.String(), b.String())
+	for _, algorithm := range []string{AlgorithmPatience, AlgorithmHistogram} {
+		got, err := UnifiedWith(file1, file2, Options{Algorithm: algorithm})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added, removed := CountChanges(got); added != 1 || removed != 1 {
+			t.Errorf("%s diff has +%d -%d lines, want +1 -1:\n%s", algorithm, added, removed, got)
+		}
+		if !strings.Contains(got, "-| cell | cell |\n+| cell | changed |\n") {
+			t.Errorf("%s diff does not replace the changed row:\n%s", algorithm, got)
+		}
+	}
+}
+
+func TestFallbackCutoff(t *testing.T) {
+	// n*n is exactly maxFallbackCells for 2048 lines, so that region is
+	// still matched by LCS and one more line is not
+	for _, tt := range []struct {
+		lines   int
+		matched bool
+	}{{2048, true}, {2049, false}} {
+		lines := make([]string, tt.lines)
+		for i := range lines {
+			lines[i] = "same"
+		}
+		l := lineDiff{a: lines, b: lines}
+		l.fallback(0, len(lines), 0, len(lines))
+		if got := len(l.matches) == tt.lines; got != tt.matched {
+			t.Errorf("%d lines: %d matches, want matched = %v", tt.lines, len(l.matches), tt.matched)
+		}
+	}
+}
@@ -2,14 +2,34 @@ package diff
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 )
 
-// ShowDiff displays the diff between two files using delta
-func ShowDiff(file1, file2 string) error {
-	cmd := exec.Command("delta", file1, file2)
+// ErrDependencyMissing is returned when a required external tool is not on PATH
+var ErrDependencyMissing = errors.New("missing required tools")
+
+// CheckDeltaArgs rejects extra delta arguments that delta would take as
+// files: every argument must be an option, with values given as --opt=value.
+func CheckDeltaArgs(args []string) error {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") || arg == "-" || arg == "--" {
+			return fmt.Errorf("invalid --delta-arg value %q (expected an option such as --side-by-side or --syntax-theme=Nord)", arg)
+		}
+	}
+	return nil
+}
+
+// ShowDiff displays the diff between two files using delta, with deltaArgs
+// inserted before the file arguments
+func ShowDiff(file1, file2 string, deltaArgs []string) error {
+	cmd := exec.Command("delta", append(slices.Clone(deltaArgs), file1, file2)...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
@@ -28,14 +48,15 @@ func ShowDiff(file1, file2 string) error {
 }
 
 // ShowDiffWithFallback tries delta first, falls back to diff
-func ShowDiffWithFallback(file1, file2 string) error {
+func ShowDiffWithFallback(file1, file2 string, deltaArgs []string) error {
 	if _, err := exec.LookPath("delta"); err != nil {
-		return showStandardDiff(file1, file2)
+		return ShowStandardDiff(file1, file2)
 	}
-	return ShowDiff(file1, file2)
+	return ShowDiff(file1, file2, deltaArgs)
 }
 
-func showStandardDiff(file1, file2 string) error {
+// ShowStandardDiff displays the diff between two files using diff -u
+func ShowStandardDiff(file1, file2 string) error {
 	cmd := exec.Command("diff", "-u", "--color=auto", file1, file2)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -53,9 +74,16 @@ func showStandardDiff(file1, file2 string) error {
 	return nil
 }
 
-// GenerateDiffFile writes a unified diff of two files to outputPath
-func GenerateDiffFile(file1, file2, outputPath string) error {
-	cmd := exec.Command("diff", "-u", file1, file2)
+// unified runs diff -u with the flags for opts
+func unified(file1, file2 string, opts Options) (string, error) {
+	args := []string{"-u"}
+	if opts.IgnoreCase {
+		args = append(args, "-i")
+	}
+	if opts.IgnoreWhitespace {
+		args = append(args, "-w")
+	}
+	cmd := exec.Command("diff", append(args, file1, file2)...)
 	var stdout bytes.Buffer
 	cmd.Stdout = &stdout
 
@@ -63,16 +91,21 @@ func GenerateDiffFile(file1, file2, outputPath string) error {
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			if exitErr.ExitCode() > 1 {
-				return fmt.Errorf("diff failed: %w", err)
+				return "", fmt.Errorf("diff failed: %w", err)
 			}
 		} else {
-			return fmt.Errorf("diff failed: %w", err)
+			return "", fmt.Errorf("diff failed: %w", err)
 		}
 	}
+	return stdout.String(), nil
+}
 
+// WriteDiffFile writes unified to outputPath fenced as a code block with the
+// info string fenceLang
+func WriteDiffFile(unified, outputPath, fenceLang string) error {
 	var wrapped bytes.Buffer
-	wrapped.WriteString("```diff\n")
-	wrapped.Write(stdout.Bytes())
+	wrapped.WriteString("```" + fenceLang + "\n")
+	wrapped.WriteString(unified)
 	if wrapped.Len() > 0 && wrapped.Bytes()[wrapped.Len()-1] != '\n' {
 		wrapped.WriteByte('\n')
 	}
@@ -81,9 +114,161 @@ func GenerateDiffFile(file1, file2, outputPath string) error {
 	return os.WriteFile(outputPath, wrapped.Bytes(), 0644)
 }
 
-// CheckDependencies checks if required external tools are available
-func CheckDependencies() error {
-	tools := []string{"markitdown", "delta", "magick"}
+// ShowUnified displays an already computed unified diff, through delta with
+// deltaArgs when it is installed and useDelta is set
+func ShowUnified(unified string, useDelta bool, deltaArgs []string) error {
+	if _, err := exec.LookPath("delta"); err != nil || !useDelta {
+		_, err := os.Stdout.WriteString(unified)
+		return err
+	}
+	cmd := exec.Command("delta", deltaArgs...)
+	cmd.Stdin = strings.NewReader(unified)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("delta failed: %w", err)
+	}
+	return nil
+}
+
+// Hunk is one @@ section of a unified diff
+type Hunk struct {
+	OldStart, OldLines int      // 1-based line range in the first file
+	NewStart, NewLines int      // 1-based line range in the second file
+	Lines              []string // hunk body lines, each prefixed with ' ', '+' or '-'
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ParseHunks splits a unified diff into its hunks.
+func ParseHunks(unified string) []Hunk {
+	var hunks []Hunk
+	for _, line := range strings.Split(unified, "\n") {
+		if m := hunkHeader.FindStringSubmatch(line); m != nil {
+			hunks = append(hunks, Hunk{
+				OldStart: atoiOr(m[1], 0),
+				OldLines: atoiOr(m[2], 1),
+				NewStart: atoiOr(m[3], 0),
+				NewLines: atoiOr(m[4], 1),
+			})
+			continue
+		}
+		if len(hunks) == 0 || line == "" || line[0] == '\\' {
+			continue
+		}
+		h := &hunks[len(hunks)-1]
+		h.Lines = append(h.Lines, line)
+	}
+	return hunks
+}
+
+// FirstChange returns the line number in the second file where the first
+// added or removed line of the hunk sits.
+func (h Hunk) FirstChange() int {
+	line := h.NewStart
+	for _, l := range h.Lines {
+		if l[0] == '+' || l[0] == '-' {
+			return line
+		}
+		line++
+	}
+	return h.NewStart
+}
+
+// DropAdditions removes pure additions from a unified diff: runs of added
+// lines that do not replace any removed line. Modifications (removed lines
+// followed by their replacement) are kept, and hunks left without changes
+// are dropped.
+func DropAdditions(unified string) string {
+	var b strings.Builder
+	lines := strings.Split(unified, "\n")
+	i := 0
+	for ; i < len(lines) && !hunkHeader.MatchString(lines[i]); i++ {
+		if lines[i] != "" {
+			b.WriteString(lines[i] + "\n")
+		}
+	}
+	header := b.String()
+	b.Reset()
+
+	// Each kept hunk moves the lines after it by the lines it adds or
+	// removes; dropped additions no longer do
+	offset := 0
+	for _, h := range ParseHunks(strings.Join(lines[i:], "\n")) {
+		var body []string
+		changed := false
+		for j := 0; j < len(h.Lines); {
+			if h.Lines[j][0] == ' ' {
+				body = append(body, h.Lines[j])
+				j++
+				continue
+			}
+			k, removed := j, false
+			for ; k < len(h.Lines) && h.Lines[k][0] != ' '; k++ {
+				removed = removed || h.Lines[k][0] == '-'
+			}
+			if removed {
+				body = append(body, h.Lines[j:k]...)
+				changed = true
+			}
+			j = k
+		}
+		if !changed {
+			continue
+		}
+		newLines := 0
+		for _, l := range body {
+			if l[0] != '-' {
+				newLines++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", h.OldStart, h.OldLines, h.OldStart+offset, newLines)
+		offset += newLines - h.OldLines
+		for _, l := range body {
+			b.WriteString(l + "\n")
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return header + b.String()
+}
+
+func atoiOr(s string, fallback int) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return fallback
+}
+
+// CountChanges counts added and removed lines in the hunks of a unified
+// diff. The ---/+++ file headers are outside the hunks, so a changed line
+// that itself starts with "-- " or "++ " is still counted.
+func CountChanges(unified string) (added, removed int) {
+	for _, h := range ParseHunks(unified) {
+		for _, line := range h.Lines {
+			switch line[0] {
+			case '+':
+				added++
+			case '-':
+				removed++
+			}
+		}
+	}
+	return added, removed
+}
+
+// CheckDependencies checks if required external tools are available,
+// including the selected markdown converter and, if withDelta and
+// withMagick are set, delta and magick
+func CheckDependencies(converter string, withDelta, withMagick bool) error {
+	tools := []string{converter}
+	if withDelta {
+		tools = append(tools, "delta")
+	}
+	if withMagick {
+		tools = append(tools, "magick")
+	}
 	var missing []string
 
 	for _, tool := range tools {
@@ -93,7 +278,7 @@ func CheckDependencies() error {
 	}
 
 	if len(missing) > 0 {
-		return fmt.Errorf("missing required tools: %v\nPlease install them before using ddx", missing)
+		return fmt.Errorf("%w: %v\nPlease install them before using ddx (run ddx doctor for install hints)", ErrDependencyMissing, missing)
 	}
 
 	return nil
@@ -0,0 +1,67 @@
+package diff
+
+import "testing"
+
+func TestDropAdditionsShiftsHunks(t *testing.T) {
+	unified := `--- a.md
++++ b.md
+@@ -1,3 +1,5 @@
+ one
++added
++also added
+ two
+ three
+@@ -10,3 +12,3 @@
+ ten
+-eleven
++ELEVEN
+ twelve
+@@ -20,3 +22,4 @@
+ twenty
+-twenty-one
++twenty-one a
++twenty-one b
+ twenty-two
+@@ -30,2 +33,2 @@
+ thirty
+-thirty-one
++THIRTY-ONE
+`
+	want := `--- a.md
++++ b.md
+@@ -10,3 +10,3 @@
+ ten
+-eleven
++ELEVEN
+ twelve
+@@ -20,3 +20,4 @@
+ twenty
+-twenty-one
++twenty-one a
++twenty-one b
+ twenty-two
+@@ -30,2 +31,2 @@
+ thirty
+-thirty-one
++THIRTY-ONE
+`
+	if got := DropAdditions(unified); got != want {
+		t.Errorf("DropAdditions() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCountChanges(t *testing.T) {
+	unified := `--- a.md
++++ b.md
+@@ -1,4 +1,4 @@
+ intro
+---- separator
+-++ old
++++ new
++--- heading rule
+ outro
+`
+	if added, removed := CountChanges(unified); added != 2 || removed != 2 {
+		t.Errorf("CountChanges() = +%d -%d, want +2 -2", added, removed)
+	}
+}
@@ -0,0 +1,38 @@
+package diff
+
+import (
+	"os"
+	"strings"
+)
+
+// WritePatch writes unified to outputPath as a patch with stable file
+// headers, see Patch
+func WritePatch(unified, outputPath, label1, label2 string) error {
+	return os.WriteFile(outputPath, []byte(Patch(unified, label1, label2)), 0644)
+}
+
+// Patch turns a unified diff into a patch that patch -p1 applies to the
+// first file: the ---/+++ headers name a/<label1>.md and b/<label2>.md instead
+// of temporary files and carry no timestamps, so they are the same on
+// every run. It is empty when there are no hunks.
+func Patch(unified, label1, label2 string) string {
+	lines := strings.SplitAfter(unified, "\n")
+	i := 0
+	for i < len(lines) && !hunkHeader.MatchString(lines[i]) {
+		i++
+	}
+	if i == len(lines) {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("--- a/" + label1 + ".md\n")
+	b.WriteString("+++ b/" + label2 + ".md\n")
+	for _, line := range lines[i:] {
+		b.WriteString(line)
+	}
+	if !strings.HasSuffix(b.String(), "\n") {
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package diff
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Layouts of diff.md
+const (
+	FormatUnified    = "unified"      // unified diff in a fenced code block
+	FormatSideBySide = "side-by-side" // GFM table with the old lines left and the new lines right
+	FormatPatch      = "patch"        // unified diff.md plus diff.patch, applicable with patch -p1
+)
+
+// Formats lists the accepted diff.md layouts
+var Formats = []string{FormatUnified, FormatSideBySide, FormatPatch}
+
+// WriteSideBySide writes unified to outputPath as a side-by-side GFM table
+// whose columns are headed label1 and label2
+func WriteSideBySide(unified, outputPath, label1, label2 string) error {
+	return os.WriteFile(outputPath, []byte(SideBySide(unified, label1, label2)), 0644)
+}
+
+// SideBySide renders a unified diff as a GFM table: line numbers and text of
+// the first file on the left and of the second on the right. Removed lines
+// are paired with the lines that replace them, marked with <del> and <ins>;
+// a row of ellipses separates hunks.
+func SideBySide(unified, label1, label2 string) string {
+	hunks := ParseHunks(unified)
+	if len(hunks) == 0 {
+		return "_No text differences._\n"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "| | %s | | %s |\n", tableCell(label1), tableCell(label2))
+	b.WriteString("|--:|---|--:|---|\n")
+	for i, h := range hunks {
+		if i > 0 {
+			b.WriteString("| … | | … | |\n")
+		}
+		oldLine, newLine := h.OldStart, h.NewStart
+		lines := h.Lines
+		for len(lines) > 0 {
+			if lines[0][0] != '-' && lines[0][0] != '+' {
+				text := tableCell(lines[0][1:])
+				fmt.Fprintf(&b, "| %d | %s | %d | %s |\n", oldLine, text, newLine, text)
+				oldLine++
+				newLine++
+				lines = lines[1:]
+				continue
+			}
+
+			// A change: removed lines followed by the lines added in their place
+			var removed, added []string
+			for len(lines) > 0 && lines[0][0] == '-' {
+				removed = append(removed, lines[0][1:])
+				lines = lines[1:]
+			}
+			for len(lines) > 0 && lines[0][0] == '+' {
+				added = append(added, lines[0][1:])
+				lines = lines[1:]
+			}
+			for k := 0; k < max(len(removed), len(added)); k++ {
+				left, right := "| | ", "| | "
+				if k < len(removed) {
+					left = fmt.Sprintf("| %d | %s ", oldLine, marked("del", removed[k]))
+					oldLine++
+				}
+				if k < len(added) {
+					right = fmt.Sprintf("| %d | %s ", newLine, marked("ins", added[k]))
+					newLine++
+				}
+				b.WriteString(left + right + "|\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// marked wraps the text of a changed line in an HTML tag such as <del>
+func marked(tag, text string) string {
+	if text == "" {
+		return ""
+	}
+	return "<" + tag + ">" + tableCell(text) + "</" + tag + ">"
+}
+
+// tableCell escapes text for a GFM table cell
+func tableCell(text string) string {
+	return strings.ReplaceAll(text, "|", `\|`)
+}
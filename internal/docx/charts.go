@@ -0,0 +1,157 @@
+package docx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// chartFile matches the chart parts below word/charts/
+var chartFile = regexp.MustCompile(`^chart(\d+)\.xml$`)
+
+// chartSpace is the part of a DrawingML chart that carries its data
+type chartSpace struct {
+	Title    []string `xml:"chart>title>tx>rich>p>r>t"`
+	PlotArea struct {
+		Plots []chartPlot `xml:",any"`
+	} `xml:"chart>plotArea"`
+}
+
+// chartPlot is one plot of a chart, such as a barChart or lineChart element.
+// Axes and layout elements decode into plots without series.
+type chartPlot struct {
+	XMLName xml.Name
+	Series  []chartSeries `xml:"ser"`
+}
+
+// chartSeries holds the cached name, categories and values of one series
+type chartSeries struct {
+	Name    []string     `xml:"tx>strRef>strCache>pt>v"`
+	RawName string       `xml:"tx>v"`
+	StrCats []chartPoint `xml:"cat>strRef>strCache>pt"`
+	NumCats []chartPoint `xml:"cat>numRef>numCache>pt"`
+	Values  []chartPoint `xml:"val>numRef>numCache>pt"`
+	XValues []chartPoint `xml:"xVal>numRef>numCache>pt"`
+	YValues []chartPoint `xml:"yVal>numRef>numCache>pt"`
+}
+
+// chartPoint is a cached value at an index
+type chartPoint struct {
+	Index int    `xml:"idx,attr"`
+	Value string `xml:"v"`
+}
+
+// Charts renders the charts of an extracted Word document as markdown: one
+// section per word/charts/chartN.xml listing every series with its
+// categories and cached values. The fallback image of a chart does not
+// always show a data change, so the text diff includes this instead. It is
+// empty for a document without charts. Charts that cannot be parsed are
+// left out and reported as warnings.
+func Charts(tempDir string) (string, []string, error) {
+	dir := filepath.Join(tempDir, "word", "charts")
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read charts: %w", err)
+	}
+
+	type chart struct {
+		name   string
+		number int
+	}
+	var charts []chart
+	for _, e := range entries {
+		if m := chartFile.FindStringSubmatch(e.Name()); m != nil && e.Type().IsRegular() {
+			n, _ := strconv.Atoi(m[1])
+			charts = append(charts, chart{name: e.Name(), number: n})
+		}
+	}
+	sort.Slice(charts, func(i, j int) bool { return charts[i].number < charts[j].number })
+
+	var b strings.Builder
+	var warnings []string
+	for _, c := range charts {
+		data, err := os.ReadFile(filepath.Join(dir, c.name))
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read %s: %w", c.name, err)
+		}
+		var space chartSpace
+		if err := xml.Unmarshal(data, &space); err != nil {
+			warnings = append(warnings, fmt.Sprintf("chart %s could not be parsed (%v); its data is not compared", c.name, err))
+			continue
+		}
+		b.WriteString("\n" + chartSection(strings.TrimSuffix(c.name, ".xml"), space))
+	}
+	return b.String(), warnings, nil
+}
+
+// chartSection renders one chart, e.g.
+//
+//	## Chart chart1: Sales
+//
+//	- barChart "2024"
+//	  - Q1: 10
+func chartSection(name string, space chartSpace) string {
+	var b strings.Builder
+	b.WriteString("## Chart " + name)
+	if title := strings.Join(space.Title, ""); title != "" {
+		b.WriteString(": " + title)
+	}
+	b.WriteString("\n\n")
+	for _, plot := range space.PlotArea.Plots {
+		for i, ser := range plot.Series {
+			fmt.Fprintf(&b, "- %s %q\n", plot.XMLName.Local, ser.name(i))
+			for _, line := range ser.points() {
+				b.WriteString("  - " + line + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// name returns the cached series name, or "Series N" when it has none
+func (s chartSeries) name(i int) string {
+	if name := strings.Join(s.Name, ""); name != "" {
+		return name
+	}
+	if s.RawName != "" {
+		return s.RawName
+	}
+	return fmt.Sprintf("Series %d", i+1)
+}
+
+// points renders the series data as "category: value" lines in index
+// order; scatter series pair their x and y values instead
+func (s chartSeries) points() []string {
+	keys, values := s.StrCats, s.Values
+	if len(keys) == 0 {
+		keys = s.NumCats
+	}
+	if len(s.YValues) > 0 {
+		keys, values = s.XValues, s.YValues
+	}
+
+	labels := make(map[int]string, len(keys))
+	for _, p := range keys {
+		labels[p.Index] = p.Value
+	}
+	sorted := append([]chartPoint(nil), values...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Index < sorted[j].Index })
+
+	lines := make([]string, 0, len(sorted))
+	for _, p := range sorted {
+		label, ok := labels[p.Index]
+		if !ok {
+			label = "#" + strconv.Itoa(p.Index+1)
+		}
+		lines = append(lines, label+": "+p.Value)
+	}
+	return lines
+}
@@ -0,0 +1,34 @@
+package docx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChartsMalformed(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "word", "charts")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	chart := `<c:chartSpace xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"><c:chart>
+<c:title><c:tx><c:rich><a:p><a:r><a:t>Sales</a:t></a:r></a:p></c:rich></c:tx></c:title>
+</c:chart></c:chartSpace>`
+	for name, content := range map[string]string{"chart1.xml": chart, "chart2.xml": "<c:chartSpace><c:chart>"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	text, warnings, err := Charts(filepath.Dir(filepath.Dir(dir)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "chart1: Sales") || strings.Contains(text, "chart2") {
+		t.Errorf("Charts() text = %q, want only chart1", text)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "chart2.xml") {
+		t.Errorf("Charts() warnings = %q, want one naming chart2.xml", warnings)
+	}
+}
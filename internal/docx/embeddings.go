@@ -0,0 +1,256 @@
+package docx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// workbook lists the sheets of an xlsx package in tab order
+type workbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// relationships maps relationship IDs to their targets
+type relationships struct {
+	Items []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// sharedStrings is the string table cells of type "s" index into
+type sharedStrings struct {
+	Items []struct {
+		Text []string `xml:"t"`
+		Runs []string `xml:"r>t"`
+	} `xml:"si"`
+}
+
+// worksheet holds the cells of one sheet
+type worksheet struct {
+	Rows []struct {
+		Number int         `xml:"r,attr"`
+		Cells  []sheetCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// sheetCell is one cell with its cached value
+type sheetCell struct {
+	Ref        string   `xml:"r,attr"`
+	Type       string   `xml:"t,attr"`
+	Value      string   `xml:"v"`
+	Inline     []string `xml:"is>t"`
+	InlineRuns []string `xml:"is>r>t"`
+}
+
+// Spreadsheets renders the spreadsheets embedded in an extracted Word
+// document (word/embeddings/*.xlsx) as an "## Embedded Spreadsheets"
+// markdown section with one line per non-empty row, so changes to embedded
+// data show up in the text diff. Binary .xls objects and corrupt workbooks
+// cannot be read and are reported as warnings. The section is empty when nothing can be shown.
+func Spreadsheets(tempDir string) (string, []string, error) {
+	dir := filepath.Join(tempDir, "word", "embeddings")
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read embeddings: %w", err)
+	}
+
+	var b strings.Builder
+	var warnings []string
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".xlsx", ".xlsm":
+			sheets, err := workbookText(filepath.Join(dir, e.Name()))
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("embedded %s could not be read (%v); its contents are not compared", e.Name(), err))
+				continue
+			}
+			b.WriteString(sheetsSection(e.Name(), sheets))
+		case ".xls":
+			warnings = append(warnings, fmt.Sprintf("embedded %s is a binary .xls workbook; its contents are not compared", e.Name()))
+		}
+	}
+	if b.Len() == 0 {
+		return "", warnings, nil
+	}
+	return "\n## Embedded Spreadsheets\n" + b.String(), warnings, nil
+}
+
+// sheet is the rendered rows of one worksheet
+type sheet struct {
+	name string
+	rows []string
+}
+
+// sheetsSection renders the sheets of one embedded workbook
+func sheetsSection(file string, sheets []sheet) string {
+	var b strings.Builder
+	for _, s := range sheets {
+		fmt.Fprintf(&b, "\n### %s: %s\n\n", file, s.name)
+		for _, row := range s.rows {
+			b.WriteString("- " + row + "\n")
+		}
+	}
+	return b.String()
+}
+
+// workbookText reads every worksheet of the xlsx package at file
+func workbookText(file string) ([]sheet, error) {
+	reader, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	files := make(map[string]*zip.File, len(reader.File))
+	for _, f := range reader.File {
+		files[f.Name] = f
+	}
+
+	var book workbook
+	if err := unmarshalPart(files, "xl/workbook.xml", &book); err != nil {
+		return nil, err
+	}
+	var rels relationships
+	if err := unmarshalPart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	var strs sharedStrings
+	if files["xl/sharedStrings.xml"] != nil {
+		if err := unmarshalPart(files, "xl/sharedStrings.xml", &strs); err != nil {
+			return nil, err
+		}
+	}
+	shared := make([]string, len(strs.Items))
+	for i, si := range strs.Items {
+		shared[i] = strings.Join(si.Text, "") + strings.Join(si.Runs, "")
+	}
+
+	targets := make(map[string]string, len(rels.Items))
+	for _, rel := range rels.Items {
+		target := strings.TrimPrefix(rel.Target, "/")
+		if !strings.HasPrefix(target, "xl/") {
+			target = path.Join("xl", target)
+		}
+		targets[rel.ID] = target
+	}
+
+	var sheets []sheet
+	for _, s := range book.Sheets {
+		var ws worksheet
+		if err := unmarshalPart(files, targets[s.RID], &ws); err != nil {
+			return nil, err
+		}
+		rendered := sheet{name: s.Name}
+		for _, row := range ws.Rows {
+			if line := rowLine(row.Cells, shared); line != "" {
+				rendered.rows = append(rendered.rows, strconv.Itoa(row.Number)+": "+line)
+			}
+		}
+		sheets = append(sheets, rendered)
+	}
+	return sheets, nil
+}
+
+// unmarshalPart decodes the XML part name of a package into v
+func unmarshalPart(files map[string]*zip.File, name string, v any) error {
+	f := files[name]
+	if f == nil {
+		return fmt.Errorf("missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+// rowLine renders the cells of a row as "a | b | c", keeping empty columns
+// between values so that a shifted cell reads as a change
+func rowLine(cells []sheetCell, shared []string) string {
+	values := make(map[int]string)
+	last := -1
+	for i, c := range cells {
+		col := i
+		if c.Ref != "" {
+			col = columnIndex(c.Ref)
+		}
+		v := cellValue(c, shared)
+		if col < 0 || v == "" {
+			continue
+		}
+		values[col] = v
+		last = max(last, col)
+	}
+	if last < 0 {
+		return ""
+	}
+	parts := make([]string, last+1)
+	for col, v := range values {
+		parts[col] = v
+	}
+	return strings.Join(parts, " | ")
+}
+
+// cellValue returns the displayed value of a cell
+func cellValue(c sheetCell, shared []string) string {
+	switch c.Type {
+	case "s":
+		if i, err := strconv.Atoi(c.Value); err == nil && i >= 0 && i < len(shared) {
+			return shared[i]
+		}
+		return ""
+	case "inlineStr":
+		return strings.Join(c.Inline, "") + strings.Join(c.InlineRuns, "")
+	case "b":
+		if c.Value == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return c.Value
+}
+
+// maxColumns is the number of columns of a worksheet (A to XFD)
+const maxColumns = 16384
+
+// columnIndex converts the column letters of a cell reference such as "C7"
+// to a 0-based index. It returns -1 when the reference has no column
+// letters or names a column past XFD.
+func columnIndex(ref string) int {
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		n = n*26 + int(r-'A'+1)
+		if n > maxColumns {
+			return -1
+		}
+	}
+	return n - 1
+}
@@ -0,0 +1,74 @@
+package docx
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRowLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		cells []sheetCell
+		want  string
+	}{
+		{"positions", []sheetCell{{Value: "1"}, {Value: "2"}}, "1 | 2"},
+		{"gap", []sheetCell{{Ref: "A1", Value: "1"}, {Ref: "C1", Value: "3"}}, "1 |  | 3"},
+		{"no column letters", []sheetCell{{Ref: "7", Value: "x"}, {Ref: "B7", Value: "2"}}, " | 2"},
+		{"past XFD", []sheetCell{{Ref: "A1", Value: "1"}, {Ref: "XFE1", Value: "x"}, {Ref: "ZZZZZZZZZZZZZZZZ1", Value: "y"}}, "1"},
+		{"only invalid", []sheetCell{{Ref: "1", Value: "x"}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rowLine(tt.cells, nil); got != tt.want {
+				t.Errorf("rowLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpreadsheetsCorruptWorkbook(t *testing.T) {
+	dir := t.TempDir()
+	embeddings := filepath.Join(dir, "word", "embeddings")
+	if err := os.MkdirAll(embeddings, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(embeddings, "Broken.xlsx"), []byte("not a zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(embeddings, "Sheet.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		"xl/workbook.xml":            `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Data" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml":   `<worksheet><sheetData><row r="1"><c r="A1" t="inlineStr"><is><t>kept</t></is></c></row></sheetData></worksheet>`,
+	} {
+		w, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	text, warnings, err := Spreadsheets(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "### Sheet.xlsx: Data") || !strings.Contains(text, "- 1: kept") {
+		t.Errorf("Spreadsheets() text = %q, want the readable workbook", text)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "Broken.xlsx") {
+		t.Errorf("Spreadsheets() warnings = %q, want one naming Broken.xlsx", warnings)
+	}
+}
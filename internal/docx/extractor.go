@@ -2,6 +2,7 @@ package docx
 
 import (
 	"archive/zip"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,16 +10,55 @@ import (
 	"strings"
 )
 
+const mediaPrefix = "word/media/"
+
+// vbaProject is the archive entry holding the macros of a macro-enabled document
+const vbaProject = "word/vbaProject.bin"
+
+// ErrNotDocx is returned when the input is not a Word document
+var ErrNotDocx = errors.New("not a Word document")
+
+// requiredParts are archive entries every Word document contains
+var requiredParts = []string{"[Content_Types].xml", "word/document.xml"}
+
 // ExtractResult holds the extraction results
 type ExtractResult struct {
 	TempDir   string            // Temporary directory containing extracted files
 	MediaDir  string            // Path to word/media directory
-	Images    map[string]string // Map of image filename to full path
+	Images    map[string]string // Map of media path (relative to word/media/) to full path
+	HasMacros bool              // Whether the document contains a VBA project (word/vbaProject.bin)
 	CleanupFn func()            // Function to cleanup temp directory
 }
 
+// Options controls extraction
+type Options struct {
+	// MediaPrefixes lists additional archive path prefixes, such as
+	// "word/media2/", whose entries are treated as media besides word/media/.
+	MediaPrefixes []string
+}
+
+// mediaName returns the image key for an archive entry, or false if the
+// entry is not media. Entries below word/media/ are keyed by the path below
+// it; entries below an additional prefix keep their full archive path so
+// they cannot collide with word/media/ names.
+func mediaName(entry string, extraPrefixes []string) (string, bool) {
+	if strings.HasPrefix(entry, mediaPrefix) {
+		return strings.TrimPrefix(entry, mediaPrefix), true
+	}
+	for _, prefix := range extraPrefixes {
+		prefix = strings.TrimPrefix(prefix, "/")
+		if !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		if strings.HasPrefix(entry, prefix) {
+			return entry, true
+		}
+	}
+	return "", false
+}
+
 // Extract extracts a docx file to a temporary directory and returns image paths
-func Extract(docxPath string) (*ExtractResult, error) {
+func Extract(docxPath string, opts Options) (*ExtractResult, error) {
 	tempDir, err := os.MkdirTemp("", "ddx-*")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp directory: %w", err)
@@ -31,15 +71,27 @@ func Extract(docxPath string) (*ExtractResult, error) {
 	reader, err := zip.OpenReader(docxPath)
 	if err != nil {
 		cleanupFn()
+		if errors.Is(err, zip.ErrFormat) {
+			return nil, fmt.Errorf("%s: %w (%w)", docxPath, ErrNotDocx, err)
+		}
 		return nil, fmt.Errorf("failed to open docx file: %w", err)
 	}
 	defer reader.Close()
 
+	if err := checkWordParts(docxPath, reader.File); err != nil {
+		cleanupFn()
+		return nil, err
+	}
+
 	images := make(map[string]string)
 	mediaDir := ""
+	hasMacros := false
 
 	for _, file := range reader.File {
 		destPath := filepath.Join(tempDir, file.Name)
+		if strings.EqualFold(file.Name, vbaProject) {
+			hasMacros = true
+		}
 
 		if file.FileInfo().IsDir() {
 			if err := os.MkdirAll(destPath, 0755); err != nil {
@@ -59,10 +111,16 @@ func Extract(docxPath string) (*ExtractResult, error) {
 			return nil, fmt.Errorf("failed to extract file %s: %w", file.Name, err)
 		}
 
-		if strings.HasPrefix(file.Name, "word/media/") {
-			fileName := filepath.Base(file.Name)
-			images[fileName] = destPath
-			if mediaDir == "" {
+		// Keep the entry's modification time so media carries its zip mtime
+		if !file.Modified.IsZero() {
+			os.Chtimes(destPath, file.Modified, file.Modified)
+		}
+
+		// Key by the path below word/media/ so that media with the same
+		// basename in different subfolders do not overwrite each other.
+		if name, ok := mediaName(file.Name, opts.MediaPrefixes); ok {
+			images[name] = destPath
+			if mediaDir == "" && strings.HasPrefix(file.Name, mediaPrefix) {
 				mediaDir = filepath.Dir(destPath)
 			}
 		}
@@ -72,10 +130,26 @@ func Extract(docxPath string) (*ExtractResult, error) {
 		TempDir:   tempDir,
 		MediaDir:  mediaDir,
 		Images:    images,
+		HasMacros: hasMacros,
 		CleanupFn: cleanupFn,
 	}, nil
 }
 
+// checkWordParts rejects zip archives that are not Word documents, such as a
+// renamed .zip or another OOXML format.
+func checkWordParts(docxPath string, files []*zip.File) error {
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[file.Name] = true
+	}
+	for _, part := range requiredParts {
+		if !present[part] {
+			return fmt.Errorf("%s: %w (missing %s)", docxPath, ErrNotDocx, part)
+		}
+	}
+	return nil
+}
+
 func extractFile(file *zip.File, destPath string) error {
 	rc, err := file.Open()
 	if err != nil {
@@ -93,7 +167,7 @@ func extractFile(file *zip.File, destPath string) error {
 	return err
 }
 
-// GetImageList returns a sorted list of image filenames
+// GetImageList returns a list of image names
 func (r *ExtractResult) GetImageList() []string {
 	var images []string
 	for name := range r.Images {
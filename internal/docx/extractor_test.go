@@ -0,0 +1,78 @@
+package docx
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeDocx writes a minimal Word document holding files to a temp directory
+func writeDocx(t *testing.T, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.docx")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	z := zip.NewWriter(f)
+	parts := map[string]string{
+		"[Content_Types].xml": "<Types/>",
+		"word/document.xml":   "<w:document/>",
+	}
+	for name, content := range files {
+		parts[name] = content
+	}
+	for name, content := range parts {
+		w, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractDuplicateBasenames(t *testing.T) {
+	media := map[string]string{
+		"word/media/image1.png":     "top",
+		"word/media/sub/image1.png": "nested",
+		"word/media/sub_image1.png": "flat",
+		"word/media2/image1.png":    "extra prefix",
+	}
+	result, err := Extract(writeDocx(t, media), Options{MediaPrefixes: []string{"word/media2/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.CleanupFn()
+
+	want := map[string]string{
+		"image1.png":             "top",
+		"sub/image1.png":         "nested",
+		"sub_image1.png":         "flat",
+		"word/media2/image1.png": "extra prefix",
+	}
+	if len(result.Images) != len(want) {
+		t.Fatalf("got %d images %v, want %d", len(result.Images), result.Images, len(want))
+	}
+	for name, content := range want {
+		path, ok := result.Images[name]
+		if !ok {
+			t.Errorf("image %s missing", name)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("image %s holds %q, want %q", name, data, content)
+		}
+	}
+}
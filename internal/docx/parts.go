@@ -0,0 +1,233 @@
+package docx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Document parts besides the body that Parts can render
+const (
+	PartHeaders   = "headers"
+	PartFooters   = "footers"
+	PartFootnotes = "footnotes"
+)
+
+var (
+	headerFile = regexp.MustCompile(`^header(\d+)\.xml$`)
+	footerFile = regexp.MustCompile(`^footer(\d+)\.xml$`)
+)
+
+// Parts renders the requested parts of an extracted Word document as
+// markdown sections, in the order given: "## Headers" and "## Footers" with
+// the text of each word/headerN.xml or word/footerN.xml, and "## Footnotes"
+// with one "[^id]: text" line per footnote. The converter leaves these
+// parts out, so without them their changes do not show in the text diff.
+// Parts without text are omitted.
+func Parts(tempDir string, kinds []string) (string, error) {
+	var b strings.Builder
+	for _, kind := range kinds {
+		var section string
+		var err error
+		switch kind {
+		case PartHeaders:
+			section, err = numberedParts(tempDir, "Headers", headerFile)
+		case PartFooters:
+			section, err = numberedParts(tempDir, "Footers", footerFile)
+		case PartFootnotes:
+			section, err = footnotes(tempDir)
+		default:
+			err = fmt.Errorf("unknown document part %q", kind)
+		}
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(section)
+	}
+	return b.String(), nil
+}
+
+// numberedParts renders the parts below word/ whose names match pattern,
+// such as header1.xml, as a section titled title
+func numberedParts(tempDir, title string, pattern *regexp.Regexp) (string, error) {
+	dir := filepath.Join(tempDir, "word")
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", strings.ToLower(title), err)
+	}
+
+	type part struct {
+		name   string
+		number int
+	}
+	var parts []part
+	for _, e := range entries {
+		if m := pattern.FindStringSubmatch(e.Name()); m != nil && e.Type().IsRegular() {
+			n, _ := strconv.Atoi(m[1])
+			parts = append(parts, part{name: e.Name(), number: n})
+		}
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].number < parts[j].number })
+
+	var b strings.Builder
+	for _, p := range parts {
+		data, err := os.ReadFile(filepath.Join(dir, p.name))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", p.name, err)
+		}
+		notes, err := partParagraphs(data)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", p.name, err)
+		}
+		if len(notes) == 0 || len(notes[0].paragraphs) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", strings.TrimSuffix(p.name, ".xml"))
+		for _, para := range notes[0].paragraphs {
+			b.WriteString(para + "\n")
+		}
+	}
+	if b.Len() == 0 {
+		return "", nil
+	}
+	return "\n## " + title + "\n" + b.String(), nil
+}
+
+// footnotes renders word/footnotes.xml, leaving out the separator notes
+// Word keeps there
+func footnotes(tempDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(tempDir, "word", "footnotes.xml"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read footnotes.xml: %w", err)
+	}
+	notes, err := partParagraphs(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse footnotes.xml: %w", err)
+	}
+
+	var b strings.Builder
+	for _, n := range notes {
+		if n.id == "" || n.kind != "" || len(n.paragraphs) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "[^%s]: %s\n", n.id, strings.Join(n.paragraphs, " "))
+	}
+	if b.Len() == 0 {
+		return "", nil
+	}
+	return "\n## Footnotes\n\n" + b.String(), nil
+}
+
+// note is the text of a footnote, or of a whole part outside footnotes
+type note struct {
+	id, kind   string
+	paragraphs []string
+}
+
+// Namespaces partParagraphs reads: WordprocessingML in its transitional and
+// strict forms, and markup compatibility
+const (
+	wordNS       = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	strictWordNS = "http://purl.oclc.org/ooxml/wordprocessingml/main"
+	compatNS     = "http://schemas.openxmlformats.org/markup-compatibility/2006"
+)
+
+// partParagraphs collects the non-empty paragraphs of a WordprocessingML
+// part. Paragraphs inside w:footnote elements go to a note each; the
+// others go to a leading note without id. A paragraph nested in another,
+// such as one in a text box, is collected on its own before the enclosing
+// one. Only w:t text counts, and mc:Fallback copies of content are
+// skipped, so DrawingML text and VML fallbacks are not read twice.
+func partParagraphs(data []byte) ([]note, error) {
+	notes := []note{{}}
+	current := &notes[0]
+	var paras []*strings.Builder // open paragraphs, innermost last
+	inParaPr := 0
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == compatNS && t.Name.Local == "Fallback" {
+				if err := dec.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if !isWordName(t.Name) {
+				continue
+			}
+			switch t.Name.Local {
+			case "footnote":
+				notes = append(notes, note{id: attr(t, "id"), kind: attr(t, "type")})
+				current = &notes[len(notes)-1]
+			case "p":
+				paras = append(paras, &strings.Builder{})
+			case "pPr":
+				inParaPr++
+			case "t":
+				if len(paras) == 0 {
+					continue
+				}
+				var text string
+				if err := dec.DecodeElement(&text, &t); err != nil {
+					return nil, err
+				}
+				paras[len(paras)-1].WriteString(text)
+			case "tab":
+				if len(paras) > 0 && inParaPr == 0 {
+					paras[len(paras)-1].WriteString("\t")
+				}
+			case "br":
+				if len(paras) > 0 {
+					paras[len(paras)-1].WriteString(" ")
+				}
+			}
+		case xml.EndElement:
+			if !isWordName(t.Name) {
+				continue
+			}
+			switch t.Name.Local {
+			case "footnote":
+				current = &notes[0]
+			case "p":
+				if len(paras) == 0 {
+					continue
+				}
+				para := paras[len(paras)-1]
+				paras = paras[:len(paras)-1]
+				if text := strings.TrimSpace(para.String()); text != "" {
+					current.paragraphs = append(current.paragraphs, text)
+				}
+			case "pPr":
+				inParaPr--
+			}
+		}
+	}
+	return notes, nil
+}
+
+// isWordName reports whether name is in the WordprocessingML namespace
+func isWordName(name xml.Name) bool {
+	return name.Space == wordNS || name.Space == strictWordNS
+}
@@ -0,0 +1,59 @@
+package docx
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testNamespaces = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+ xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006"
+ xmlns:wps="http://schemas.microsoft.com/office/word/2010/wordprocessingShape"
+ xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"
+ xmlns:v="urn:schemas-microsoft-com:vml"`
+
+func TestPartParagraphsTextBox(t *testing.T) {
+	header := `<w:hdr ` + testNamespaces + `>
+<w:p>
+  <w:pPr><w:tabs><w:tab w:val="center" w:pos="4680"/></w:tabs></w:pPr>
+  <w:r><w:t>Outer before</w:t></w:r>
+  <w:r><mc:AlternateContent>
+    <mc:Choice Requires="wps"><w:drawing><wps:txbx><w:txbxContent>
+      <w:p><w:r><w:t>Box text</w:t></w:r></w:p>
+    </w:txbxContent></wps:txbx><a:t>DrawingML label</a:t></w:drawing></mc:Choice>
+    <mc:Fallback><w:pict><v:textbox><w:txbxContent>
+      <w:p><w:r><w:t>Box text</w:t></w:r></w:p>
+    </w:txbxContent></v:textbox></w:pict></mc:Fallback>
+  </mc:AlternateContent></w:r>
+  <w:r><w:tab/><w:t>after</w:t></w:r>
+</w:p>
+<w:p><w:r><w:t>Second</w:t></w:r></w:p>
+</w:hdr>`
+	notes, err := partParagraphs([]byte(header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Box text", "Outer before\tafter", "Second"}
+	if len(notes) != 1 || !reflect.DeepEqual(notes[0].paragraphs, want) {
+		t.Errorf("partParagraphs() = %+v, want one note with %q", notes, want)
+	}
+}
+
+func TestPartParagraphsFootnotes(t *testing.T) {
+	footnotes := `<w:footnotes ` + testNamespaces + `>
+<w:footnote w:type="separator" w:id="-1"><w:p><w:r><w:separator/></w:r></w:p></w:footnote>
+<w:footnote w:id="1"><w:p><w:r><w:t>First note</w:t></w:r></w:p><w:p><w:r><w:t>continued</w:t></w:r></w:p></w:footnote>
+</w:footnotes>`
+	notes, err := partParagraphs([]byte(footnotes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 3 {
+		t.Fatalf("got %d notes, want 3: %+v", len(notes), notes)
+	}
+	if got := notes[2]; got.id != "1" || !reflect.DeepEqual(got.paragraphs, []string{"First note", "continued"}) {
+		t.Errorf("footnote 1 = %+v", got)
+	}
+	if got := notes[1]; got.kind != "separator" || len(got.paragraphs) != 0 {
+		t.Errorf("separator = %+v", got)
+	}
+}
@@ -0,0 +1,290 @@
+package docx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// runProps holds the run properties that are reported in structure markdown
+type runProps struct {
+	bold, italic, underline, strike bool
+	color, highlight, font, size    string
+}
+
+// String renders the properties as space-separated attributes, e.g.
+// "b color=FF0000 sz=12". It is empty for a plain run.
+func (p runProps) String() string {
+	var attrs []string
+	for _, flag := range []struct {
+		on   bool
+		name string
+	}{{p.bold, "b"}, {p.italic, "i"}, {p.underline, "u"}, {p.strike, "s"}} {
+		if flag.on {
+			attrs = append(attrs, flag.name)
+		}
+	}
+	for _, kv := range []struct{ key, val string }{
+		{"color", p.color}, {"highlight", p.highlight}, {"font", p.font}, {"sz", p.size},
+	} {
+		if kv.val != "" {
+			attrs = append(attrs, kv.key+"="+kv.val)
+		}
+	}
+	return strings.Join(attrs, " ")
+}
+
+// run is a piece of paragraph text with uniform properties
+type run struct {
+	text  string
+	props string
+}
+
+// Structure renders the paragraph styles and key run properties of an
+// extracted Word document as "structure markdown": one line per paragraph
+// such as "[H2] Introduction" or "[Normal] Some [bold]{b} text". Diffing it
+// catches formatting-only changes that the markdown conversion drops.
+func Structure(tempDir string) (string, error) {
+	names, err := styleNames(filepath.Join(tempDir, "word", "styles.xml"))
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(filepath.Join(tempDir, "word", "document.xml"))
+	if err != nil {
+		return "", fmt.Errorf("failed to open document.xml: %w", err)
+	}
+	defer f.Close()
+
+	out, err := structure(f, names)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse document.xml: %w", err)
+	}
+	return out, nil
+}
+
+// styledPara is a paragraph being read by structure
+type styledPara struct {
+	style    string
+	runs     []run
+	props    runProps // properties of the current run
+	inParaPr bool
+}
+
+// structure renders the structure markdown of a document part. Like
+// partParagraphs, it reads only WordprocessingML elements, renders a
+// paragraph nested in another (such as one in a text box) on its own line
+// before the enclosing one, and skips mc:Fallback copies.
+func structure(r io.Reader, names map[string]string) (string, error) {
+	var (
+		b       strings.Builder
+		paras   []*styledPara // open paragraphs, innermost last
+		inRunPr bool
+	)
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		var para *styledPara
+		if len(paras) > 0 {
+			para = paras[len(paras)-1]
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == compatNS && t.Name.Local == "Fallback" {
+				if err := dec.Skip(); err != nil {
+					return "", err
+				}
+				continue
+			}
+			if !isWordName(t.Name) {
+				continue
+			}
+			if t.Name.Local == "p" {
+				paras = append(paras, &styledPara{})
+				continue
+			}
+			if para == nil {
+				continue
+			}
+			switch t.Name.Local {
+			case "pPr":
+				para.inParaPr = true
+			case "pStyle":
+				if para.inParaPr {
+					para.style = attr(t, "val")
+				}
+			case "r":
+				para.props = runProps{}
+			case "rPr":
+				// Paragraph mark properties (pPr/rPr) do not apply to any text
+				inRunPr = !para.inParaPr
+			case "b", "i", "u", "strike", "color", "highlight", "rFonts", "sz":
+				if inRunPr {
+					para.props.set(t)
+				}
+			case "t":
+				var text string
+				if err := dec.DecodeElement(&text, &t); err != nil {
+					return "", err
+				}
+				para.runs = appendRun(para.runs, run{text: text, props: para.props.String()})
+			case "tab":
+				if !para.inParaPr {
+					para.runs = appendRun(para.runs, run{text: "\t", props: para.props.String()})
+				}
+			}
+		case xml.EndElement:
+			if !isWordName(t.Name) || para == nil {
+				continue
+			}
+			switch t.Name.Local {
+			case "pPr":
+				para.inParaPr = false
+			case "rPr":
+				inRunPr = false
+			case "p":
+				paras = paras[:len(paras)-1]
+				if len(para.runs) > 0 {
+					b.WriteString(paragraphLine(displayStyle(para.style, names), para.runs))
+				}
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// set records the property given by a run property element
+func (p *runProps) set(el xml.StartElement) {
+	val := attr(el, "val")
+	off := val == "0" || val == "false" || val == "none"
+	switch el.Name.Local {
+	case "b":
+		p.bold = !off
+	case "i":
+		p.italic = !off
+	case "u":
+		p.underline = !off
+	case "strike":
+		p.strike = !off
+	case "color":
+		if val != "auto" {
+			p.color = strings.ToUpper(val)
+		}
+	case "highlight":
+		if !off {
+			p.highlight = val
+		}
+	case "rFonts":
+		p.font = attr(el, "ascii")
+	case "sz":
+		// w:sz is in half-points
+		if n, err := strconv.Atoi(val); err == nil {
+			p.size = strconv.FormatFloat(float64(n)/2, 'f', -1, 64)
+		}
+	}
+}
+
+// appendRun adds r to runs, merging it into the last run when the
+// properties are the same
+func appendRun(runs []run, r run) []run {
+	if n := len(runs); n > 0 && runs[n-1].props == r.props {
+		runs[n-1].text += r.text
+		return runs
+	}
+	return append(runs, r)
+}
+
+// paragraphLine renders one paragraph of structure markdown
+func paragraphLine(style string, runs []run) string {
+	var b strings.Builder
+	b.WriteString("[" + style + "] ")
+	for _, r := range runs {
+		if r.props == "" {
+			b.WriteString(r.text)
+			continue
+		}
+		fmt.Fprintf(&b, "[%s]{%s}", r.text, r.props)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// displayStyle maps a paragraph style ID to its label: H1-H9 for headings,
+// otherwise the style name from styles.xml (Normal when unset)
+func displayStyle(id string, names map[string]string) string {
+	if id == "" {
+		return "Normal"
+	}
+	name := names[id]
+	if name == "" {
+		name = id
+	}
+	if level, ok := strings.CutPrefix(strings.ToLower(name), "heading "); ok {
+		if _, err := strconv.Atoi(level); err == nil {
+			return "H" + level
+		}
+	}
+	return name
+}
+
+// styleNames maps style IDs to their names. A missing styles.xml yields an
+// empty map, so style IDs are used as they are.
+func styleNames(path string) (map[string]string, error) {
+	names := make(map[string]string)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return names, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open styles.xml: %w", err)
+	}
+	defer f.Close()
+
+	var id string
+	dec := xml.NewDecoder(f)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse styles.xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "style":
+				id = attr(t, "styleId")
+			case "name":
+				if id != "" {
+					names[id] = attr(t, "val")
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "style" {
+				id = ""
+			}
+		}
+	}
+}
+
+// attr returns the value of the attribute with the given local name
+func attr(el xml.StartElement, local string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
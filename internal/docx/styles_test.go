@@ -0,0 +1,42 @@
+package docx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStructureTextBox(t *testing.T) {
+	document := `<w:document ` + testNamespaces + `><w:body>
+<w:p>
+  <w:pPr><w:pStyle w:val="Title"/><w:tabs><w:tab w:val="center" w:pos="4680"/></w:tabs></w:pPr>
+  <w:r><w:t>Outer before</w:t></w:r>
+  <w:r><mc:AlternateContent>
+    <mc:Choice Requires="wps"><w:drawing><wps:txbx><w:txbxContent>
+      <w:p><w:r><w:rPr><w:b/></w:rPr><w:t>Box text</w:t></w:r></w:p>
+    </w:txbxContent></wps:txbx><a:p><a:r><a:t>DrawingML label</a:t></a:r></a:p></w:drawing></mc:Choice>
+    <mc:Fallback><w:pict><v:textbox><w:txbxContent>
+      <w:p><w:r><w:rPr><w:b/></w:rPr><w:t>Box text</w:t></w:r></w:p>
+    </w:txbxContent></v:textbox></w:pict></mc:Fallback>
+  </mc:AlternateContent></w:r>
+  <w:r><w:tab/><w:t>after</w:t></w:r>
+</w:p>
+<w:p><w:r><w:t>Second</w:t></w:r></w:p>
+</w:body></w:document>`
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "word"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "word", "document.xml"), []byte(document), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Structure(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[Normal] [Box text]{b}\n[Title] Outer before\tafter\n[Normal] Second\n"
+	if got != want {
+		t.Errorf("Structure() = %q, want %q", got, want)
+	}
+}
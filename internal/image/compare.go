@@ -2,8 +2,11 @@ package image
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -12,18 +15,27 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/shioshosho/diff-docx/internal/retry"
 )
 
 // ImageInfo holds a name and path for an image
 type ImageInfo struct {
-	Name string // filename e.g. "image1.png"
-	Path string // full path e.g. "/tmp/ddx-xxx/word/media/image1.png"
+	Name    string    // media path below word/media/ e.g. "image1.png" or "sub/image1.png"
+	Path    string    // full path e.g. "/tmp/ddx-xxx/word/media/image1.png"
+	ModTime time.Time // modification time, taken from the zip entry for docx media
+	SHA256  string    // hex SHA-256 of the file content, empty if it could not be read
+	Size    int64     // file size in bytes
+	Skip    string    // why the image was not compared, for MatchResult.Skipped and Broken
 }
 
 // MatchedPair represents two images with identical content
 type MatchedPair struct {
-	Image1 ImageInfo
-	Image2 ImageInfo
+	Image1   ImageInfo
+	Image2   ImageInfo
+	PSNR     float64 // +Inf for pixel-identical images, -1 if unknown
+	DiffPath string  // kept heatmap (name1-name2.identical.ext), with MatchOptions.KeepIdenticalDiffs
 }
 
 // DiffPair represents two images with different content
@@ -31,7 +43,15 @@ type DiffPair struct {
 	Image1   ImageInfo
 	Image2   ImageInfo
 	PSNR     float64
-	DiffPath string // path to generated diff image in diff/imgs/
+	DiffPath string             // path to generated diff image in diff/imgs/
+	Metrics  map[string]float64 // values of the requested MatchOptions.Metrics, keyed by metric name
+	Dims1    Dimensions         // pixel size of Image1, zero if unknown
+	Dims2    Dimensions         // pixel size of Image2, zero if unknown
+}
+
+// Resized reports whether both sizes are known and differ
+func (p DiffPair) Resized() bool {
+	return p.Dims1.Known() && p.Dims2.Known() && p.Dims1 != p.Dims2
 }
 
 // MatchResult holds the structured result of image set comparison
@@ -41,11 +61,116 @@ type MatchResult struct {
 	OnlyIn1   []ImageInfo
 	OnlyIn2   []ImageInfo
 	Skipped   []ImageInfo
+	Broken    []ImageInfo // empty or unreadable media, not compared; Skip is "empty", "missing" or "unreadable"
+}
+
+// MatchOptions controls how image sets are compared
+type MatchOptions struct {
+	ConvertPNG    bool              // convert vector images to PNG via ImageMagick before comparison
+	StripMetadata bool              // auto-orient and strip metadata from raster images before comparison
+	DiffFormat    string            // diff image format written by magick compare: png (default), webp or avif
+	Retry         retry.Policy      // retry policy for magick compare
+	Since         time.Time         // if set, images modified before this time are skipped
+	MaxDimension  int               // if > 0, pairs larger than this many pixels are downscaled before comparison
+	IgnoreHashes  []string          // SHA-256 digests of images to drop from every bucket
+	Metrics       []string          // metrics recorded on changed pairs, from Metrics; PSNR is always used for matching
+	Manifest      bool              // write ManifestName with the SHA-256 of each diff image
+	Exclude       map[string]string // paths of images to skip without comparing, with the reason, e.g. matching alt text
+	Range         ImageRange        // if set, only media at these positions of each document are compared
+
+	// KeepIdenticalDiffs keeps the heatmap magick writes for pairs that
+	// turn out identical, as <name1>-<name2>.identical.<ext>, for debugging
+	// misclassified pairs.
+	KeepIdenticalDiffs bool
+
+	// Fuzz, if > 0, is passed to magick compare as -fuzz <Fuzz>%: colors
+	// within this distance count as equal, which hides anti-aliasing noise.
+	Fuzz float64
+
+	// DiffName names the diff images: {name1} and {name2} stand for the
+	// image names without extension. Empty means DefaultDiffName. A name
+	// already used in the run gets "-2", "-3", ... appended.
+	DiffName string
+
+	// TempSuffix is appended to the name of the diff image magick compare
+	// writes before it is renamed. Empty means DefaultTempSuffix.
+	TempSuffix string
+
+	// CrossFormat, after matching within each extension, compares the
+	// raster images left in only one document against those of other
+	// extensions, so that a figure re-exported from PNG to JPEG is matched
+	// instead of reported as removed and added.
+	CrossFormat bool
+
+	// LosslessThreshold and LossyThreshold override the PSNR below which a
+	// pair counts as different, per format class. Zero means the default
+	// (PSNRThreshold, LossyPSNRThreshold).
+	LosslessThreshold float64
+	LossyThreshold    float64
+
+	// NoMagick compares images without ImageMagick: PNG, JPEG and GIF are
+	// decoded in Go and other formats are skipped. ConvertPNG,
+	// StripMetadata, MaxDimension and metrics other than psnr need
+	// ImageMagick and are rejected or ignored.
+	NoMagick bool
+
+	// Progress, if set, is called after each image comparison with the
+	// number of comparisons done and the total planned.
+	Progress func(done, total int)
+}
+
+// Defaults for MatchOptions.DiffName and MatchOptions.TempSuffix
+const (
+	DefaultDiffName   = "{name1}-{name2}"
+	DefaultTempSuffix = "_cmp"
+)
+
+// CheckDiffName rejects a DiffName template that would not yield a plain
+// file name in the diff image directory.
+func CheckDiffName(template string) error {
+	if strings.TrimSpace(template) == "" || strings.ContainsAny(template, `/\`) || template == "." || template == ".." {
+		return fmt.Errorf("invalid diff image name %q (expected a file name such as %s)", template, DefaultDiffName)
+	}
+	return nil
+}
+
+// Metrics lists the supported comparison metrics, in display order. "ae" is
+// the absolute error: the number of pixels that differ.
+var Metrics = []string{"psnr", "ssim", "ae"}
+
+// DiffFormats lists the supported diff image formats
+var DiffFormats = []string{"png", "webp", "avif"}
+
+// SupportsFormat reports whether the installed ImageMagick can write format.
+func SupportsFormat(format string) bool {
+	out, err := exec.Command("magick", "-list", "format").Output()
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSuffix(fields[0], "*"), format) {
+			return strings.Contains(fields[2], "w")
+		}
+	}
+	return false
 }
 
-// PSNRThreshold is the threshold below which images are considered different
+// PSNRThreshold is the threshold below which images are considered different.
+// It is the default for lossless formats.
 const PSNRThreshold = 1.0
 
+// LossyPSNRThreshold is the default threshold for lossy formats (JPEG, WebP),
+// looser than PSNRThreshold to tolerate re-encoding artifacts.
+const LossyPSNRThreshold = 0.5
+
+var lossyExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".webp": true,
+}
+
 var rasterExts = map[string]bool{
 	".png": true, ".jpg": true, ".jpeg": true,
 	".bmp": true, ".gif": true, ".tiff": true,
@@ -61,8 +186,11 @@ var hasLibreOffice = sync.OnceValue(func() bool {
 	return err == nil
 })
 
-func canCompareExt(ext string, convertPNG bool) bool {
+func canCompareExt(ext string, convertPNG, noMagick bool) bool {
 	ext = strings.ToLower(ext)
+	if noMagick {
+		return goExts[ext]
+	}
 	if rasterExts[ext] {
 		return true
 	}
@@ -72,10 +200,36 @@ func canCompareExt(ext string, convertPNG bool) bool {
 	return false
 }
 
+// isLossy reports whether ext belongs to the lossy format class. Vector
+// formats are compared as PNG and therefore count as lossless.
+func isLossy(ext string) bool {
+	return lossyExts[strings.ToLower(ext)]
+}
+
+// flatName turns a media name that may contain subfolders into a single
+// file name component.
+func flatName(name string) string {
+	return strings.ReplaceAll(name, "/", "_")
+}
+
+// UniquePath returns destDir/file, or destDir/<base>-2<ext>, -3, ... when
+// that path is already in use, and records the result. Flattened names can
+// collide, e.g. sub/image1.png and sub_image1.png.
+func UniquePath(destDir, file string, used map[string]bool) string {
+	ext := filepath.Ext(file)
+	path := filepath.Join(destDir, file)
+	for i := 2; used[path]; i++ {
+		path = filepath.Join(destDir, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(file, ext), i, ext))
+	}
+	used[path] = true
+	return path
+}
+
 // convertToPNG converts an image to PNG using ImageMagick magick convert.
-func convertToPNG(srcPath, destDir string) (string, error) {
-	base := strings.TrimSuffix(filepath.Base(srcPath), filepath.Ext(srcPath))
-	dstPath := filepath.Join(destDir, base+".png")
+// used holds the paths already written, see UniquePath.
+func convertToPNG(srcPath, name, destDir string, used map[string]bool) (string, error) {
+	base := strings.TrimSuffix(flatName(name), filepath.Ext(name))
+	dstPath := UniquePath(destDir, base+".png", used)
 
 	cmd := exec.Command("magick", "convert", srcPath, dstPath)
 	var stderr bytes.Buffer
@@ -87,16 +241,114 @@ func convertToPNG(srcPath, destDir string) (string, error) {
 	return dstPath, nil
 }
 
+// stripMetadata writes an auto-oriented copy of a raster image without
+// metadata (EXIF, ICC profiles, comments) to destDir. used holds the paths
+// already written, see UniquePath.
+func stripMetadata(srcPath, name, destDir string, used map[string]bool) (string, error) {
+	dstPath := UniquePath(destDir, flatName(name), used)
+
+	cmd := exec.Command("magick", srcPath, "-auto-orient", "-strip", dstPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("magick strip failed for %s: %w\n%s", srcPath, err, stderr.String())
+	}
+	return dstPath, nil
+}
+
+// matcher holds the state shared by all extension groups of a MatchImageSets run
+type matcher struct {
+	tempDir     string
+	diffImgsDir string
+	result      *MatchResult
+	cmpPaths    map[string]string // original image path -> converted or normalized path for comparison
+	diffExt     string            // extension of generated diff images, e.g. ".png"
+	retry       retry.Policy
+	progress    func(done, total int)
+	done        int
+	total       int
+	metricNames []string // metrics recorded on changed pairs
+
+	keepIdentical bool    // keep heatmaps of identical pairs
+	fuzz          float64 // magick compare -fuzz percentage, 0 for none
+
+	diffName   string          // diff image name template, see MatchOptions.DiffName
+	tempSuffix string          // suffix of the diff image magick compare writes
+	usedNames  map[string]bool // diff image paths handed out in this run
+
+	losslessThreshold float64
+	lossyThreshold    float64
+
+	noMagick     bool // compare with compareGo instead of magick compare
+	maxDimension int
+	dims         map[string][2]int // image path -> width, height
+	scaledPaths  map[string]string // "<factor>|<path>" -> downscaled copy
+}
+
+// threshold returns the PSNR threshold for a pair. When the two names belong
+// to different format classes the stricter (lossless) threshold applies.
+func (m *matcher) threshold(name1, name2 string) float64 {
+	if isLossy(filepath.Ext(name1)) && isLossy(filepath.Ext(name2)) {
+		return m.lossyThreshold
+	}
+	return m.losslessThreshold
+}
+
+// advance records n comparisons as done and reports progress.
+func (m *matcher) advance(n int) {
+	if n <= 0 {
+		return
+	}
+	m.done += n
+	if m.progress != nil {
+		m.progress(m.done, m.total)
+	}
+}
+
+// plannedComparisons returns the worst-case number of compare calls for an
+// extension group: every pair in Phase 1 plus order-based pairs in Phase 2.
+func plannedComparisons(n1, n2 int) int {
+	return n1*n2 + min(n1, n2)
+}
+
 type imageEntry struct {
-	name string
-	path string
+	name    string
+	path    string
+	modTime time.Time
+	hash    string
+	size    int64
+	broken  string // why the file cannot be compared: "empty", "missing" or "unreadable"
+}
+
+func (e imageEntry) info() ImageInfo {
+	return ImageInfo{Name: e.name, Path: e.path, ModTime: e.modTime, SHA256: e.hash, Size: e.size}
 }
 
 func groupByExt(images map[string]string) map[string][]imageEntry {
 	groups := make(map[string][]imageEntry)
 	for name, path := range images {
 		ext := strings.ToLower(filepath.Ext(name))
-		groups[ext] = append(groups[ext], imageEntry{name, path})
+		entry := imageEntry{name: name, path: path}
+		info, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err):
+			entry.broken = "missing"
+		case err != nil:
+			entry.broken = "unreadable"
+		default:
+			entry.modTime = info.ModTime()
+			entry.size = info.Size()
+			if entry.size == 0 {
+				entry.broken = "empty"
+			}
+		}
+		if hash, err := fileSHA256(path); err == nil {
+			entry.hash = hash
+		} else if entry.broken == "" {
+			entry.broken = "unreadable"
+		}
+		groups[ext] = append(groups[ext], entry)
 	}
 	for ext := range groups {
 		sort.Slice(groups[ext], func(i, j int) bool {
@@ -106,22 +358,161 @@ func groupByExt(images map[string]string) map[string][]imageEntry {
 	return groups
 }
 
+// dropIgnored removes images whose content hash is in ignore from groups.
+func dropIgnored(groups map[string][]imageEntry, ignore map[string]bool) {
+	for ext, list := range groups {
+		var kept []imageEntry
+		for _, img := range list {
+			if !ignore[img.hash] {
+				kept = append(kept, img)
+			}
+		}
+		if len(kept) == 0 {
+			delete(groups, ext)
+		} else {
+			groups[ext] = kept
+		}
+	}
+}
+
+// skipWhere moves images for which skip returns a reason from groups to
+// into, such as result.Skipped.
+func skipWhere(groups map[string][]imageEntry, skip func(imageEntry) string, into *[]ImageInfo) {
+	exts := make([]string, 0, len(groups))
+	for ext := range groups {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+
+	for _, ext := range exts {
+		var kept []imageEntry
+		for _, img := range groups[ext] {
+			if reason := skip(img); reason != "" {
+				info := img.info()
+				info.Skip = reason
+				*into = append(*into, info)
+				continue
+			}
+			kept = append(kept, img)
+		}
+		if len(kept) == 0 {
+			delete(groups, ext)
+		} else {
+			groups[ext] = kept
+		}
+	}
+}
+
+// dimensions returns the pixel size of an image, cached per path.
+func (m *matcher) dimensions(path string) (width, height int, err error) {
+	if d, ok := m.dims[path]; ok {
+		return d[0], d[1], nil
+	}
+	out, err := exec.Command("magick", "identify", "-format", "%w %h\n", path).Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("magick identify failed for %s: %w", path, err)
+	}
+	// Multi-frame images print one line per frame; the first is enough
+	line := strings.SplitN(strings.TrimSpace(string(out)), "\n", 2)[0]
+	if _, err := fmt.Sscanf(line, "%d %d", &width, &height); err != nil {
+		return 0, 0, fmt.Errorf("unexpected magick identify output for %s: %q", path, line)
+	}
+	m.dims[path] = [2]int{width, height}
+	return width, height, nil
+}
+
+// scaled returns a copy of path resized by factor, cached per path and factor.
+func (m *matcher) scaled(path string, factor float64) (string, error) {
+	pct := strconv.FormatFloat(factor*100, 'f', 4, 64) + "%"
+	key := pct + "|" + path
+	if p, ok := m.scaledPaths[key]; ok {
+		return p, nil
+	}
+
+	dir := filepath.Join(m.tempDir, "scaled", strconv.Itoa(len(m.scaledPaths)))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	dstPath := filepath.Join(dir, filepath.Base(path))
+
+	cmd := exec.Command("magick", path, "-resize", pct, dstPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("magick resize failed for %s: %w\n%s", path, err, stderr.String())
+	}
+	m.scaledPaths[key] = dstPath
+	return dstPath, nil
+}
+
+// boundPair downscales both images by the same factor when either exceeds
+// the maximum dimension, so that equally sized images stay equally sized.
+func (m *matcher) boundPair(image1, image2 string) (string, string, error) {
+	if m.maxDimension <= 0 || m.noMagick {
+		return image1, image2, nil
+	}
+	w1, h1, err := m.dimensions(image1)
+	if err != nil {
+		return "", "", err
+	}
+	w2, h2, err := m.dimensions(image2)
+	if err != nil {
+		return "", "", err
+	}
+	largest := max(w1, h1, w2, h2)
+	if largest <= m.maxDimension {
+		return image1, image2, nil
+	}
+
+	factor := float64(m.maxDimension) / float64(largest)
+	scaled1, err := m.scaled(image1, factor)
+	if err != nil {
+		return "", "", err
+	}
+	scaled2, err := m.scaled(image2, factor)
+	if err != nil {
+		return "", "", err
+	}
+	return scaled1, scaled2, nil
+}
+
 // compare runs ImageMagick compare and returns the result
-func compare(image1, image2, outputDir string) (isDifferent bool, psnr float64, diffPath string, err error) {
+func (m *matcher) compare(image1, image2, outputDir string, threshold float64) (isDifferent bool, psnr float64, diffPath string, err error) {
+	if m.noMagick {
+		return m.compareGo(image1, image2, outputDir, threshold)
+	}
+	image1, image2, err = m.boundPair(image1, image2)
+	if err != nil {
+		return false, -1, "", err
+	}
+
 	baseName := strings.TrimSuffix(filepath.Base(image1), filepath.Ext(image1))
-	diffPath = filepath.Join(outputDir, baseName+"_cmp.png")
+	diffPath = filepath.Join(outputDir, baseName+m.tempSuffix+m.diffExt)
 
-	cmd := exec.Command("magick", "compare", "-verbose", "-metric", "PSNR", image1, image2, diffPath)
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	runErr := cmd.Run()
+	newCmd := func() *exec.Cmd {
+		stdout.Reset()
+		stderr.Reset()
+		args := append([]string{"compare", "-verbose"}, m.fuzzArgs()...)
+		cmd := exec.Command("magick", append(args, "-metric", "PSNR", image1, image2, diffPath)...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		return cmd
+	}
+	runErr := m.retry.Run(newCmd, compareRetryable(&stderr))
+	if err := m.retry.Err(); err != nil {
+		return false, -1, "", err
+	}
 	output := stderr.String() + stdout.String()
 
-	isDifferent, psnr = parsePSNROutput(output)
+	isDifferent, psnr = parsePSNROutput(output, threshold)
+	// Without a readable metric, fall back to magick's verdict: exit status 1
+	// means the images differ
+	if exitErr, ok := runErr.(*exec.ExitError); ok && psnr < 0 && exitErr.ExitCode() == 1 {
+		isDifferent = true
+	}
 
-	if !isDifferent {
+	if !isDifferent && !m.keepIdentical {
 		os.Remove(diffPath)
 		diffPath = ""
 	}
@@ -137,53 +528,259 @@ func compare(image1, image2, outputDir string) (isDifferent bool, psnr float64,
 	return isDifferent, psnr, diffPath, nil
 }
 
-func parsePSNROutput(output string) (isDifferent bool, psnr float64) {
-	channelPattern := regexp.MustCompile(`(?i)(red|green|blue|all):\s*([\d.]+|inf)`)
-	matches := channelPattern.FindAllStringSubmatch(output, -1)
+// fuzzArgs returns the -fuzz option for magick compare, if any
+func (m *matcher) fuzzArgs() []string {
+	if m.fuzz <= 0 {
+		return nil
+	}
+	return []string{"-fuzz", strconv.FormatFloat(m.fuzz, 'f', -1, 64) + "%"}
+}
+
+// compareRetryable reports whether a failed magick compare should be
+// retried. Exit status 1 only means the images differ.
+func compareRetryable(stderr *bytes.Buffer) func(error) bool {
+	return func(err error) bool {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return false
+		}
+		return retry.Transient(err, stderr.String())
+	}
+}
+
+// metric runs magick compare with the named metric (e.g. "ssim") and returns
+// the value it reports.
+func (m *matcher) metric(name, image1, image2 string) (float64, error) {
+	image1, image2, err := m.boundPair(image1, image2)
+	if err != nil {
+		return -1, err
+	}
+
+	var stderr bytes.Buffer
+	newCmd := func() *exec.Cmd {
+		stderr.Reset()
+		args := append([]string{"compare"}, m.fuzzArgs()...)
+		cmd := exec.Command("magick", append(args, "-metric", strings.ToUpper(name), image1, image2, "null:")...)
+		cmd.Stderr = &stderr
+		return cmd
+	}
+	runErr := m.retry.Run(newCmd, compareRetryable(&stderr))
+
+	match := metricPattern.FindStringSubmatch(stderr.String())
+	if match == nil {
+		if runErr != nil {
+			return -1, fmt.Errorf("ImageMagick compare failed: %w\nOutput: %s", runErr, stderr.String())
+		}
+		return -1, fmt.Errorf("unexpected ImageMagick compare output: %q", stderr.String())
+	}
+	return strconv.ParseFloat(match[1], 64)
+}
+
+var metricPattern = regexp.MustCompile(`^\s*([\d.]+(?:[eE][-+]?\d+)?)`)
+
+// metrics collects the requested metrics for a changed pair.
+func (m *matcher) metrics(image1, image2 string, psnr float64) (map[string]float64, error) {
+	if len(m.metricNames) == 0 {
+		return nil, nil
+	}
+	values := make(map[string]float64, len(m.metricNames))
+	for _, name := range m.metricNames {
+		if name == "psnr" {
+			values[name] = psnr
+			continue
+		}
+		if m.noMagick {
+			return nil, fmt.Errorf("metric %s needs ImageMagick", name)
+		}
+		value, err := m.metric(name, image1, image2)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// FormatMetrics formats metric values in display order, e.g.
+// "PSNR 18.200, SSIM 0.940, 42,318 px changed".
+func FormatMetrics(values map[string]float64) string {
+	var parts []string
+	for _, name := range Metrics {
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+		switch name {
+		case "psnr":
+			parts = append(parts, "PSNR "+FormatPSNR(value))
+		case "ae":
+			parts = append(parts, GroupThousands(int64(value))+" px changed")
+		default:
+			parts = append(parts, strings.ToUpper(name)+" "+strconv.FormatFloat(value, 'f', 3, 64))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GroupThousands formats n with comma thousands separators, e.g. "42,318".
+func GroupThousands(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String()
+}
+
+// psnrChannel matches a channel line of magick compare -verbose output,
+// e.g. "    all: 23.4 (0.234)"
+var psnrChannel = regexp.MustCompile(`(?im)^\s*(red|green|blue|gray|cyan|magenta|yellow|black|alpha|all):\s*(inf|[\d.]+(?:e[-+]?\d+)?)`)
+
+// psnrValueLine matches the bare metric line magick compare prints without
+// -verbose, e.g. "23.4 (0.234)" or "inf"
+var psnrValueLine = regexp.MustCompile(`(?im)^\s*(inf|[\d.]+(?:e[-+]?\d+)?)(?:\s+\([^)]*\))?\s*$`)
+
+// parsePSNROutput reads the per-channel PSNR values of magick compare
+// -verbose output and reports whether any is below threshold, along with the
+// lowest value. An infinite PSNR on the "all" channel means the pixels are
+// identical, however the files are encoded, and settles the result. Without
+// channel lines the bare metric line is used; psnr is -1 when the output has
+// neither.
+func parsePSNROutput(output string, threshold float64) (isDifferent bool, psnr float64) {
+	matches := psnrChannel.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		if m := psnrValueLine.FindStringSubmatch(output); m != nil {
+			matches = [][]string{{m[0], "all", m[1]}}
+		}
+	}
 
 	psnr = -1
+	sawInf := false
 	for _, match := range matches {
-		if len(match) >= 3 {
-			value := match[2]
-			if strings.ToLower(value) == "inf" {
-				continue
-			}
-			psnrValue, err := strconv.ParseFloat(value, 64)
-			if err != nil {
-				continue
-			}
-			if psnr < 0 || psnrValue < psnr {
-				psnr = psnrValue
-			}
-			if psnrValue < PSNRThreshold {
-				isDifferent = true
+		if strings.EqualFold(match[2], "inf") {
+			if strings.EqualFold(match[1], "all") {
+				return false, math.Inf(1)
 			}
+			sawInf = true
+			continue
 		}
-	}
-
-	if psnr < 0 {
-		if strings.Contains(output, " 0 ") || strings.Contains(output, " 0\n") {
+		value, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			continue
+		}
+		if psnr < 0 || value < psnr {
+			psnr = value
+		}
+		if value < threshold {
 			isDifferent = true
-			psnr = 0
-		} else {
-			psnr = -1
 		}
 	}
 
+	if psnr < 0 && sawInf {
+		psnr = math.Inf(1)
+	}
 	return isDifferent, psnr
 }
 
+// FormatPSNR formats a PSNR value for display, rendering identical images as "inf".
+func FormatPSNR(psnr float64) string {
+	if math.IsInf(psnr, 1) {
+		return "inf"
+	}
+	return strconv.FormatFloat(psnr, 'f', 3, 64)
+}
+
+// SortKeys lists the supported orderings for SortDifferent
+var SortKeys = []string{"psnr", "name"}
+
+// SortDifferent reorders r.Different. "psnr" puts the most different pairs
+// (lowest PSNR) first and pairs with unknown PSNR last; "name" sorts by the
+// image names. Any other key keeps the matching order.
+func (r *MatchResult) SortDifferent(key string) {
+	byName := func(a, b DiffPair) bool {
+		if a.Image1.Name != b.Image1.Name {
+			return a.Image1.Name < b.Image1.Name
+		}
+		return a.Image2.Name < b.Image2.Name
+	}
+
+	switch key {
+	case "psnr":
+		sort.SliceStable(r.Different, func(i, j int) bool {
+			a, b := r.Different[i], r.Different[j]
+			if (a.PSNR < 0) != (b.PSNR < 0) {
+				return b.PSNR < 0
+			}
+			if a.PSNR != b.PSNR {
+				return a.PSNR < b.PSNR
+			}
+			return byName(a, b)
+		})
+	case "name":
+		sort.SliceStable(r.Different, func(i, j int) bool {
+			return byName(r.Different[i], r.Different[j])
+		})
+	}
+}
+
 // MatchImageSets compares two image sets using content-based matching and
 // outputs diff artifacts to diffImgsDir.
-func MatchImageSets(images1, images2 map[string]string, diffImgsDir string, convertPNG bool ) (*MatchResult, error) {
+func MatchImageSets(images1, images2 map[string]string, diffImgsDir string, opts MatchOptions) (*MatchResult, error) {
+	if opts.NoMagick && (opts.ConvertPNG || opts.StripMetadata) {
+		return nil, fmt.Errorf("PNG conversion and metadata stripping need ImageMagick")
+	}
+	if err := checkWritable(diffImgsDir); err != nil {
+		return nil, err
+	}
+
 	tempDir, err := os.MkdirTemp("", "ddx-match-*")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temp directory: %w", err)
 	}
 	defer os.RemoveAll(tempDir)
 
+	result := &MatchResult{}
+
 	groups1 := groupByExt(images1)
 	groups2 := groupByExt(images2)
+	if opts.Range != (ImageRange{}) {
+		skipWhere(groups1, outsideRange(images1, opts.Range), &result.Skipped)
+		skipWhere(groups2, outsideRange(images2, opts.Range), &result.Skipped)
+	}
+	// Empty or unreadable media, such as a failed extraction, would fail
+	// every comparison and end up reported as added and removed
+	broken := func(img imageEntry) string { return img.broken }
+	skipWhere(groups1, broken, &result.Broken)
+	skipWhere(groups2, broken, &result.Broken)
+	if len(opts.IgnoreHashes) > 0 {
+		ignore := make(map[string]bool, len(opts.IgnoreHashes))
+		for _, h := range opts.IgnoreHashes {
+			ignore[strings.ToLower(h)] = true
+		}
+		dropIgnored(groups1, ignore)
+		dropIgnored(groups2, ignore)
+	}
+	if !opts.Since.IsZero() {
+		older := func(img imageEntry) string {
+			if img.modTime.Before(opts.Since) {
+				return "modified before " + opts.Since.Format(time.RFC3339)
+			}
+			return ""
+		}
+		skipWhere(groups1, older, &result.Skipped)
+		skipWhere(groups2, older, &result.Skipped)
+	}
+	if len(opts.Exclude) > 0 {
+		excluded := func(img imageEntry) string { return opts.Exclude[img.path] }
+		skipWhere(groups1, excluded, &result.Skipped)
+		skipWhere(groups2, excluded, &result.Skipped)
+	}
 
 	allExts := make(map[string]bool)
 	for ext := range groups1 {
@@ -198,13 +795,11 @@ func MatchImageSets(images1, images2 map[string]string, diffImgsDir string, conv
 	}
 	sort.Strings(sortedExts)
 
-	result := &MatchResult{}
-
-	// cmpPaths maps original image path -> converted PNG path for comparison
 	cmpPaths := make(map[string]string)
+	written := make(map[string]bool) // converted and stripped copies, see UniquePath
 
-	// Convert vector images to PNG if convertPNG is enabled
-	if convertPNG {
+	// Convert vector images to PNG if ConvertPNG is enabled
+	if opts.ConvertPNG {
 		convertDir1 := filepath.Join(tempDir, "converted", "doc1")
 		convertDir2 := filepath.Join(tempDir, "converted", "doc2")
 		for _, d := range []string{convertDir1, convertDir2} {
@@ -218,14 +813,14 @@ func MatchImageSets(images1, images2 map[string]string, diffImgsDir string, conv
 				continue
 			}
 			for _, img := range groups1[ext] {
-				pngPath, err := convertToPNG(img.path, convertDir1)
+				pngPath, err := convertToPNG(img.path, img.name, convertDir1, written)
 				if err != nil {
 					return nil, fmt.Errorf("failed to convert %s to PNG: %w", img.name, err)
 				}
 				cmpPaths[img.path] = pngPath
 			}
 			for _, img := range groups2[ext] {
-				pngPath, err := convertToPNG(img.path, convertDir2)
+				pngPath, err := convertToPNG(img.path, img.name, convertDir2, written)
 				if err != nil {
 					return nil, fmt.Errorf("failed to convert %s to PNG: %w", img.name, err)
 				}
@@ -234,21 +829,119 @@ func MatchImageSets(images1, images2 map[string]string, diffImgsDir string, conv
 		}
 	}
 
+	// Normalize orientation and strip metadata from raster images
+	if opts.StripMetadata {
+		stripDir1 := filepath.Join(tempDir, "stripped", "doc1")
+		stripDir2 := filepath.Join(tempDir, "stripped", "doc2")
+		for _, d := range []string{stripDir1, stripDir2} {
+			if err := os.MkdirAll(d, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create strip directory: %w", err)
+			}
+		}
+
+		for _, ext := range sortedExts {
+			if !rasterExts[ext] {
+				continue
+			}
+			for _, img := range groups1[ext] {
+				strippedPath, err := stripMetadata(img.path, img.name, stripDir1, written)
+				if err != nil {
+					return nil, fmt.Errorf("failed to strip metadata from %s: %w", img.name, err)
+				}
+				cmpPaths[img.path] = strippedPath
+			}
+			for _, img := range groups2[ext] {
+				strippedPath, err := stripMetadata(img.path, img.name, stripDir2, written)
+				if err != nil {
+					return nil, fmt.Errorf("failed to strip metadata from %s: %w", img.name, err)
+				}
+				cmpPaths[img.path] = strippedPath
+			}
+		}
+	}
+
+	m := &matcher{
+		tempDir:     tempDir,
+		diffImgsDir: diffImgsDir,
+		result:      result,
+		cmpPaths:    cmpPaths,
+		progress:    opts.Progress,
+		diffExt:     ".png",
+		retry:       opts.Retry,
+		metricNames: opts.Metrics,
+
+		keepIdentical: opts.KeepIdenticalDiffs,
+		fuzz:          opts.Fuzz,
+
+		diffName:   DefaultDiffName,
+		tempSuffix: DefaultTempSuffix,
+		usedNames:  make(map[string]bool),
+
+		losslessThreshold: PSNRThreshold,
+		lossyThreshold:    LossyPSNRThreshold,
+
+		noMagick:     opts.NoMagick,
+		maxDimension: opts.MaxDimension,
+		dims:         make(map[string][2]int),
+		scaledPaths:  make(map[string]string),
+	}
+	if opts.LosslessThreshold > 0 {
+		m.losslessThreshold = opts.LosslessThreshold
+	}
+	if opts.LossyThreshold > 0 {
+		m.lossyThreshold = opts.LossyThreshold
+	}
+	if opts.DiffFormat != "" && !opts.NoMagick {
+		m.diffExt = "." + strings.ToLower(opts.DiffFormat)
+	}
+	if opts.DiffName != "" {
+		m.diffName = opts.DiffName
+	}
+	if opts.TempSuffix != "" {
+		m.tempSuffix = opts.TempSuffix
+	}
+	for _, ext := range sortedExts {
+		if canCompareExt(ext, opts.ConvertPNG, opts.NoMagick) {
+			m.total += plannedComparisons(len(groups1[ext]), len(groups2[ext]))
+		}
+	}
+
 	for _, ext := range sortedExts {
 		list1 := groups1[ext]
 		list2 := groups2[ext]
 
-		if !canCompareExt(ext, convertPNG) {
-			for _, img := range list1 {
-				result.Skipped = append(result.Skipped, ImageInfo{img.name, img.path})
+		if !canCompareExt(ext, opts.ConvertPNG, opts.NoMagick) {
+			reason := "unsupported format " + ext
+			if opts.NoMagick && (rasterExts[ext] || vectorExts[ext]) {
+				reason = "ImageMagick is not used; only PNG, JPEG and GIF are compared"
+			} else if vectorExts[ext] {
+				reason = "vector image; PNG conversion is off and LibreOffice is not installed"
 			}
-			for _, img := range list2 {
-				result.Skipped = append(result.Skipped, ImageInfo{img.name, img.path})
+			for _, img := range append(list1, list2...) {
+				info := img.info()
+				info.Skip = reason
+				result.Skipped = append(result.Skipped, info)
 			}
 			continue
 		}
 
-		if err := matchExtGroup(list1, list2, tempDir, diffImgsDir, result, cmpPaths); err != nil {
+		if err := m.matchExtGroup(list1, list2); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.CrossFormat {
+		if err := m.matchAcrossFormats(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Manifest {
+		manifest, err := BuildManifest(result)
+		if err != nil {
+			return nil, err
+		}
+		if err := manifest.Write(filepath.Join(diffImgsDir, ManifestName)); err != nil {
 			return nil, err
 		}
 	}
@@ -257,34 +950,49 @@ func MatchImageSets(images1, images2 map[string]string, diffImgsDir string, conv
 }
 
 // cmpPath returns the comparison path for an image, using the converted PNG path if available.
-func cmpPath(originalPath string, cmpPaths map[string]string) string {
-	if p, ok := cmpPaths[originalPath]; ok {
+func (m *matcher) cmpPath(originalPath string) string {
+	if p, ok := m.cmpPaths[originalPath]; ok {
 		return p
 	}
 	return originalPath
 }
 
-func matchExtGroup(list1, list2 []imageEntry, tempDir, diffImgsDir string, result *MatchResult, cmpPaths map[string]string) error {
+func (m *matcher) matchExtGroup(list1, list2 []imageEntry) error {
+	result := m.result
 	matched1 := make(map[int]bool)
 	matched2 := make(map[int]bool)
+	planned := plannedComparisons(len(list1), len(list2))
+	start := m.done
 
 	// Phase 1: find identical pairs by content
 	for i, img1 := range list1 {
 		for j, img2 := range list2 {
 			if matched2[j] {
+				m.advance(1)
 				continue
 			}
-			isDiff, _, _, err := compare(cmpPath(img1.path, cmpPaths), cmpPath(img2.path, cmpPaths), tempDir)
+			isDiff, psnr, tmpDiffPath, err := m.compare(m.cmpPath(img1.path), m.cmpPath(img2.path), m.tempDir, m.threshold(img1.name, img2.name))
+			m.advance(1)
+			if err := m.retry.Err(); err != nil {
+				return err
+			}
 			if err != nil {
 				continue
 			}
 			if !isDiff {
 				matched1[i] = true
 				matched2[j] = true
+				keptPath, err := m.keepDiff(tmpDiffPath, img1.name, img2.name, ".identical")
+				if err != nil {
+					return err
+				}
 				result.Matched = append(result.Matched, MatchedPair{
-					Image1: ImageInfo{img1.name, img1.path},
-					Image2: ImageInfo{img2.name, img2.path},
+					Image1:   img1.info(),
+					Image2:   img2.info(),
+					PSNR:     psnr,
+					DiffPath: keptPath,
 				})
+				m.advance(len(list2) - j - 1)
 				break
 			}
 		}
@@ -312,41 +1020,263 @@ func matchExtGroup(list1, list2 []imageEntry, tempDir, diffImgsDir string, resul
 		img1 := unmatched1[i]
 		img2 := unmatched2[i]
 
-		isDiff, psnr, tmpDiffPath, err := compare(cmpPath(img1.path, cmpPaths), cmpPath(img2.path, cmpPaths), diffImgsDir)
+		isDiff, psnr, tmpDiffPath, err := m.compare(m.cmpPath(img1.path), m.cmpPath(img2.path), m.tempDir, m.threshold(img1.name, img2.name))
+		m.advance(1)
 		if err != nil {
 			return fmt.Errorf("failed to compare %s vs %s: %w", img1.name, img2.name, err)
 		}
 
-		// Rename diff image to name1-name2.ext
-		finalDiffPath := ""
-		if isDiff && tmpDiffPath != "" {
-			ext := filepath.Ext(img1.name)
-			base1 := strings.TrimSuffix(img1.name, ext)
-			base2 := strings.TrimSuffix(img2.name, ext)
-			finalDiffPath = filepath.Join(diffImgsDir, base1+"-"+base2+".png")
-			os.Rename(tmpDiffPath, finalDiffPath)
+		// Phase 1 pairs greedily and skips pairs it failed to compare, so a
+		// pair left over for Phase 2 can still be identical
+		if !isDiff {
+			keptPath, err := m.keepDiff(tmpDiffPath, img1.name, img2.name, ".identical")
+			if err != nil {
+				return err
+			}
+			result.Matched = append(result.Matched, MatchedPair{
+				Image1:   img1.info(),
+				Image2:   img2.info(),
+				PSNR:     psnr,
+				DiffPath: keptPath,
+			})
+			continue
+		}
+
+		// Move the diff image to name1-name2.ext
+		finalDiffPath, err := m.keepDiff(tmpDiffPath, img1.name, img2.name, "")
+		if err != nil {
+			return err
+		}
+
+		metrics, err := m.metrics(m.cmpPath(img1.path), m.cmpPath(img2.path), psnr)
+		if err != nil {
+			return fmt.Errorf("failed to measure %s vs %s: %w", img1.name, img2.name, err)
 		}
 
 		result.Different = append(result.Different, DiffPair{
-			Image1:   ImageInfo{img1.name, img1.path},
-			Image2:   ImageInfo{img2.name, img2.path},
+			Image1:   img1.info(),
+			Image2:   img2.info(),
 			PSNR:     psnr,
 			DiffPath: finalDiffPath,
+			Metrics:  metrics,
+			Dims1:    m.imageDimensions(img1.path),
+			Dims2:    m.imageDimensions(img2.path),
 		})
 	}
 
+	// Account for Phase 2 comparisons that were not needed
+	m.advance(start + planned - m.done)
+
 	// Phase 3: only in one side
 	for i := minLen; i < len(unmatched1); i++ {
-		result.OnlyIn1 = append(result.OnlyIn1, ImageInfo{unmatched1[i].name, unmatched1[i].path})
+		result.OnlyIn1 = append(result.OnlyIn1, unmatched1[i].info())
 	}
 	for i := minLen; i < len(unmatched2); i++ {
-		result.OnlyIn2 = append(result.OnlyIn2, ImageInfo{unmatched2[i].name, unmatched2[i].path})
+		result.OnlyIn2 = append(result.OnlyIn2, unmatched2[i].info())
+	}
+
+	return nil
+}
+
+// matchAcrossFormats matches raster images left in only one document with
+// identical images of another extension. Pairs that still differ are left
+// as removed and added: with formats changing, pairing them by order would
+// mostly pair unrelated figures.
+func (m *matcher) matchAcrossFormats() error {
+	result := m.result
+	crossable := func(img1, img2 ImageInfo) bool {
+		ext1 := strings.ToLower(filepath.Ext(img1.Name))
+		ext2 := strings.ToLower(filepath.Ext(img2.Name))
+		if m.noMagick {
+			return ext1 != ext2 && goExts[ext1] && goExts[ext2]
+		}
+		return ext1 != ext2 && rasterExts[ext1] && rasterExts[ext2]
+	}
+	planned := 0
+	for _, img1 := range result.OnlyIn1 {
+		for _, img2 := range result.OnlyIn2 {
+			if crossable(img1, img2) {
+				planned++
+			}
+		}
+	}
+	if planned == 0 {
+		return nil
+	}
+	m.total += planned
+	start := m.done
+
+	matched1 := make(map[int]bool)
+	matched2 := make(map[int]bool)
+	for i, img1 := range result.OnlyIn1 {
+		for j, img2 := range result.OnlyIn2 {
+			if matched2[j] || !crossable(img1, img2) {
+				continue
+			}
+			isDiff, psnr, tmpDiffPath, err := m.compare(m.cmpPath(img1.Path), m.cmpPath(img2.Path), m.tempDir, m.threshold(img1.Name, img2.Name))
+			m.advance(1)
+			if err := m.retry.Err(); err != nil {
+				return err
+			}
+			if err != nil || isDiff {
+				continue
+			}
+			keptPath, err := m.keepDiff(tmpDiffPath, img1.Name, img2.Name, ".identical")
+			if err != nil {
+				return err
+			}
+			matched1[i] = true
+			matched2[j] = true
+			result.Matched = append(result.Matched, MatchedPair{
+				Image1:   img1,
+				Image2:   img2,
+				PSNR:     psnr,
+				DiffPath: keptPath,
+			})
+			break
+		}
 	}
+	m.advance(start + planned - m.done)
 
+	result.OnlyIn1 = unmatched(result.OnlyIn1, matched1)
+	result.OnlyIn2 = unmatched(result.OnlyIn2, matched2)
 	return nil
 }
 
-// CopyFile copies a file from src to dst
+// unmatched returns the images of list whose index is not in matched
+func unmatched(list []ImageInfo, matched map[int]bool) []ImageInfo {
+	var kept []ImageInfo
+	for i, img := range list {
+		if !matched[i] {
+			kept = append(kept, img)
+		}
+	}
+	return kept
+}
+
+// keepDiff moves a diff image written by compare to the name diffPath
+// gives the pair in the diff image directory and returns the new path, or ""
+// if there is no diff image.
+func (m *matcher) keepDiff(tmpDiffPath, name1, name2, suffix string) (string, error) {
+	if tmpDiffPath == "" {
+		return "", nil
+	}
+	finalDiffPath := m.diffPath(name1, name2, suffix)
+	// Comparisons write to the temp directory, which may be on another
+	// device than the diff image directory: fall back to copying
+	if err := os.Rename(tmpDiffPath, finalDiffPath); err != nil {
+		if err := CopyFile(tmpDiffPath, finalDiffPath); err != nil {
+			return "", fmt.Errorf("failed to save diff image %s: %w", finalDiffPath, err)
+		}
+		os.Remove(tmpDiffPath)
+	}
+	return finalDiffPath, nil
+}
+
+// diffPath returns <diffName><suffix>.<ext> in the diff image directory for
+// a pair, with "-2", "-3", ... before the suffix when the name was already
+// used in this run, so pairs with the same names do not overwrite each other.
+func (m *matcher) diffPath(name1, name2, suffix string) string {
+	base := strings.NewReplacer(
+		"{name1}", strings.TrimSuffix(flatName(name1), filepath.Ext(name1)),
+		"{name2}", strings.TrimSuffix(flatName(name2), filepath.Ext(name2)),
+	).Replace(m.diffName)
+	path := filepath.Join(m.diffImgsDir, base+suffix+m.diffExt)
+	for i := 2; m.usedNames[path]; i++ {
+		path = filepath.Join(m.diffImgsDir, fmt.Sprintf("%s-%d%s%s", base, i, suffix, m.diffExt))
+	}
+	m.usedNames[path] = true
+	return path
+}
+
+// checkWritable creates dir if needed and makes sure files can be created
+// in it, so that a permission problem is reported before any comparison
+// instead of as a failing magick compare
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create diff image directory: %w", err)
+	}
+	f, err := os.CreateTemp(dir, ".ddx-write-check-*")
+	if err != nil {
+		return fmt.Errorf("diff image directory %s is not writable: %w", dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// ImagesFromDir builds an image map, as produced by docx extraction, from the
+// files below dir. Names are slash-separated paths relative to dir; hidden
+// files are ignored.
+func ImagesFromDir(dir string) (map[string]string, error) {
+	images := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		images[filepath.ToSlash(rel)] = path
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// SameContent reports whether two files exist and have identical size and
+// SHA-256 digest.
+func SameContent(path1, path2 string) (bool, error) {
+	info1, err := os.Stat(path1)
+	if err != nil {
+		return false, err
+	}
+	info2, err := os.Stat(path2)
+	if err != nil {
+		return false, err
+	}
+	if info1.Size() != info2.Size() {
+		return false, nil
+	}
+
+	hash1, err := fileSHA256(path1)
+	if err != nil {
+		return false, err
+	}
+	hash2, err := fileSHA256(path2)
+	if err != nil {
+		return false, err
+	}
+	return hash1 == hash2, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CopyFile copies a file from src to dst, preserving the source's
+// permissions and modification time
 func CopyFile(src, dst string) error {
 	in, err := os.Open(src)
 	if err != nil {
@@ -354,6 +1284,11 @@ func CopyFile(src, dst string) error {
 	}
 	defer in.Close()
 
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
 	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
 		return err
 	}
@@ -362,8 +1297,17 @@ func CopyFile(src, dst string) error {
 	if err != nil {
 		return err
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, in)
-	return err
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
 }
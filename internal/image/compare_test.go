@@ -0,0 +1,172 @@
+package image
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePSNROutput(t *testing.T) {
+	tests := []struct {
+		name      string
+		output    string
+		different bool
+		psnr      float64
+	}{
+		{
+			name: "IM7 verbose, differing",
+			output: `a.png PNG 40x30 40x30+0+0 8-bit sRGB 1242B 0.000u 0:00.000
+b.png PNG 40x30 40x30+0+0 8-bit sRGB 1250B 0.000u 0:00.000
+Image: a.png
+  Channel distortion: PSNR
+    red: 0.361742 (0.00361742)
+    green: 0.452301 (0.00452301)
+    blue: 1.20455 (0.0120455)
+    all: 0.452301 (0.00452301)
+a.png=>diff_cmp.png PNG 40x30 40x30+0+0 8-bit sRGB 1.1KiB 0.010u 0:00.004
+`,
+			different: true,
+			psnr:      0.361742,
+		},
+		{
+			name: "IM7 verbose, within threshold",
+			output: `Image: a.png
+  Channel distortion: PSNR
+    red: 1.8 (0.018)
+    green: 2.5 (0.025)
+    blue: 1.1 (0.011)
+    all: 1.6 (0.016)
+`,
+			different: false,
+			psnr:      1.1,
+		},
+		{
+			name: "IM6 verbose",
+			output: `Image: a.png
+  Channel distortion: PSNR
+    red: 17.4243
+    green: 21.7812
+    blue: 0.91
+    all: 1.5
+`,
+			different: true,
+			psnr:      0.91,
+		},
+		{
+			name: "IM6 verbose, grayscale",
+			output: `Image: a.png
+  Channel distortion: PSNR
+    gray: 3.5
+    all: 3.5
+`,
+			different: false,
+			psnr:      3.5,
+		},
+		{
+			name:      "bare metric line",
+			output:    "12.3 (0.123)\n",
+			different: false,
+			psnr:      12.3,
+		},
+		{
+			name:      "bare metric line below threshold",
+			output:    "0.5 (0.005)\n",
+			different: true,
+			psnr:      0.5,
+		},
+		{
+			name:      "exponent",
+			output:    "    all: 1.5e-05 (1.5e-07)\n",
+			different: true,
+			psnr:      1.5e-05,
+		},
+		{
+			name: "IM7 verbose, identical",
+			output: `Image: a.png
+  Channel distortion: PSNR
+    red: inf (inf)
+    green: inf (inf)
+    blue: inf (inf)
+    all: inf (inf)
+`,
+			different: false,
+			psnr:      math.Inf(1),
+		},
+		{
+			name: "inf on all settles identical",
+			output: `    red: 0.2
+    all: inf
+`,
+			different: false,
+			psnr:      math.Inf(1),
+		},
+		{
+			name: "inf on one channel, finite all",
+			output: `Image: a.png
+  Channel distortion: PSNR
+    red: inf (inf)
+    green: 0.35 (0.0035)
+    blue: inf (inf)
+    all: 0.8 (0.008)
+`,
+			different: true,
+			psnr:      0.35,
+		},
+		{
+			name:      "inf on every channel without all",
+			output:    "    red: inf\n    green: inf\n",
+			different: false,
+			psnr:      math.Inf(1),
+		},
+		{
+			name:      "bare inf",
+			output:    "inf\n",
+			different: false,
+			psnr:      math.Inf(1),
+		},
+		{
+			name:      "no metric",
+			output:    "compare: unable to open image 'a.png'\n",
+			different: false,
+			psnr:      -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			different, psnr := parsePSNROutput(tt.output, PSNRThreshold)
+			if different != tt.different || !samePSNR(psnr, tt.psnr) {
+				t.Errorf("parsePSNROutput() = %v, %v; want %v, %v", different, psnr, tt.different, tt.psnr)
+			}
+		})
+	}
+}
+
+// samePSNR compares PSNR values, treating two infinities as equal
+func samePSNR(a, b float64) bool {
+	if math.IsInf(a, 1) || math.IsInf(b, 1) {
+		return math.IsInf(a, 1) && math.IsInf(b, 1)
+	}
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestUniquePathFlattenedCollisions(t *testing.T) {
+	used := make(map[string]bool)
+	var got []string
+	for _, name := range []string{"sub/image1.png", "sub_image1.png", "sub/image1.png", "image1.png"} {
+		got = append(got, UniquePath("dir", flatName(name), used))
+	}
+	want := []string{"dir/sub_image1.png", "dir/sub_image1-2.png", "dir/sub_image1-3.png", "dir/image1.png"}
+	for i := range want {
+		if got[i] != filepath.FromSlash(want[i]) {
+			t.Errorf("UniquePath #%d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestGroupThousands(t *testing.T) {
+	for n, want := range map[int64]string{0: "0", 999: "999", 1000: "1,000", 4210: "4,210", 1234567: "1,234,567", -42318: "-42,318"} {
+		if got := GroupThousands(n); got != want {
+			t.Errorf("GroupThousands(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package image
+
+import (
+	"fmt"
+	stdimage "image"
+	_ "image/gif"  // register GIF for DecodeConfig
+	_ "image/jpeg" // register JPEG for DecodeConfig
+	_ "image/png"  // register PNG for DecodeConfig
+	"os"
+)
+
+// Dimensions is the pixel size of an image. The zero value means unknown.
+type Dimensions struct {
+	Width, Height int
+}
+
+// String renders the size as e.g. "1024x768"
+func (d Dimensions) String() string {
+	return fmt.Sprintf("%dx%d", d.Width, d.Height)
+}
+
+// Known reports whether the size could be read
+func (d Dimensions) Known() bool {
+	return d.Width > 0 && d.Height > 0
+}
+
+// SameAspect reports whether d and o have the same aspect ratio
+func (d Dimensions) SameAspect(o Dimensions) bool {
+	return d.Width*o.Height == o.Width*d.Height
+}
+
+// imageDimensions returns the pixel size of the image at path, read from
+// its header for PNG, JPEG and GIF and with magick identify otherwise. It
+// is zero when neither can read the file, e.g. for some vector formats, or
+// without ImageMagick.
+func (m *matcher) imageDimensions(path string) Dimensions {
+	if f, err := os.Open(path); err == nil {
+		config, _, err := stdimage.DecodeConfig(f)
+		f.Close()
+		if err == nil {
+			return Dimensions{Width: config.Width, Height: config.Height}
+		}
+	}
+	if m.noMagick {
+		return Dimensions{}
+	}
+	width, height, err := m.dimensions(path)
+	if err != nil {
+		return Dimensions{}
+	}
+	return Dimensions{Width: width, Height: height}
+}
@@ -0,0 +1,113 @@
+package image
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ImageRange selects media by position in the name-sorted media list of a
+// document, counting from 1 with both ends included. A zero Start or End
+// leaves that side open; the zero value selects everything.
+type ImageRange struct {
+	Start, End int
+}
+
+// ParseImageRange parses "<start>:<end>", e.g. "10:20", "10:" or ":20"
+func ParseImageRange(s string) (ImageRange, error) {
+	start, end, ok := strings.Cut(s, ":")
+	if !ok || start == "" && end == "" {
+		return ImageRange{}, fmt.Errorf("invalid image range %q (expected <start>:<end>, e.g. 10:20)", s)
+	}
+	var r ImageRange
+	for _, side := range []struct {
+		text string
+		into *int
+	}{{start, &r.Start}, {end, &r.End}} {
+		if side.text == "" {
+			continue
+		}
+		n, err := strconv.Atoi(side.text)
+		if err != nil || n < 1 {
+			return ImageRange{}, fmt.Errorf("invalid image range %q: positions start at 1", s)
+		}
+		*side.into = n
+	}
+	if r.End > 0 && r.Start > r.End {
+		return ImageRange{}, fmt.Errorf("invalid image range %q: start is after end", s)
+	}
+	return r, nil
+}
+
+// String renders the range as ParseImageRange accepts it
+func (r ImageRange) String() string {
+	var start, end string
+	if r.Start > 0 {
+		start = strconv.Itoa(r.Start)
+	}
+	if r.End > 0 {
+		end = strconv.Itoa(r.End)
+	}
+	return start + ":" + end
+}
+
+// contains reports whether the 1-based position pos is in the range
+func (r ImageRange) contains(pos int) bool {
+	return pos >= r.Start && (r.End == 0 || pos <= r.End)
+}
+
+// outsideRange returns a skipWhere function for the media of one document
+// that skips those outside r
+func outsideRange(images map[string]string, r ImageRange) func(imageEntry) string {
+	names := make([]string, 0, len(images))
+	for name := range images {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return numericLess(names[i], names[j]) })
+	positions := make(map[string]int, len(names))
+	for i, name := range names {
+		positions[name] = i + 1
+	}
+	reason := "outside image range " + r.String()
+	return func(img imageEntry) string {
+		if r.contains(positions[img.name]) {
+			return ""
+		}
+		return reason
+	}
+}
+
+// numericLess orders names with runs of digits compared by value, so that
+// image2.png comes before image10.png
+func numericLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da != "" && db != "" {
+			na := strings.TrimLeft(da, "0")
+			nb := strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+// digitPrefix returns the leading run of ASCII digits of s
+func digitPrefix(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
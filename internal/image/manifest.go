@@ -0,0 +1,89 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ManifestName is the file name of the diff image manifest in the diff
+// image directory
+const ManifestName = "manifest.json"
+
+// Manifest maps each generated diff image, by file name, to its SHA-256
+type Manifest struct {
+	Images map[string]string `json:"images"`
+}
+
+// Drift is a diff image whose content differs from a previous manifest.
+// Old is empty for an image the previous manifest did not list; New is
+// empty for one that was not generated this time.
+type Drift struct {
+	Image string `json:"image"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// BuildManifest hashes the diff images of the changed pairs in result
+func BuildManifest(result *MatchResult) (*Manifest, error) {
+	manifest := &Manifest{Images: make(map[string]string)}
+	for _, pair := range result.Different {
+		if pair.DiffPath == "" {
+			continue
+		}
+		sum, err := fileSHA256(pair.DiffPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %w", pair.DiffPath, err)
+		}
+		manifest.Images[filepath.Base(pair.DiffPath)] = sum
+	}
+	return manifest, nil
+}
+
+// ReadManifest loads a manifest written by Manifest.Write
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	if manifest.Images == nil {
+		manifest.Images = make(map[string]string)
+	}
+	return &manifest, nil
+}
+
+// Write writes m as indented JSON to path
+func (m *Manifest) Write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// Compare lists the images whose hashes differ between m and current,
+// sorted by name
+func (m *Manifest) Compare(current *Manifest) []Drift {
+	var drift []Drift
+	for name, old := range m.Images {
+		if sum := current.Images[name]; sum != old {
+			drift = append(drift, Drift{Image: name, Old: old, New: sum})
+		}
+	}
+	for name, sum := range current.Images {
+		if _, ok := m.Images[name]; !ok {
+			drift = append(drift, Drift{Image: name, New: sum})
+		}
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Image < drift[j].Image })
+	return drift
+}
@@ -0,0 +1,140 @@
+package image
+
+import (
+	"fmt"
+	stdimage "image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// goExts are the formats the standard library decodes, and so the only ones
+// compared with MatchOptions.NoMagick
+var goExts = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+}
+
+// psnrScale is the PSNR in dB that magick compare reports as 1: its values,
+// and so the thresholds, are normalized by this peak
+const psnrScale = 48.1647
+
+// decodeFile reads and decodes the image at path
+func decodeFile(path string) (stdimage.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := stdimage.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// compareGo is compare without ImageMagick. It computes the PSNR of the red,
+// green and blue channels and reports the lowest on the scale of magick
+// compare -metric PSNR. Pixels outside the smaller of two differently sized images
+// count as completely different. The diff image is a faded copy of image1
+// with the differing pixels in red; it is always a PNG.
+func (m *matcher) compareGo(image1, image2, outputDir string, threshold float64) (isDifferent bool, psnr float64, diffPath string, err error) {
+	img1, err := decodeFile(image1)
+	if err != nil {
+		return false, -1, "", err
+	}
+	img2, err := decodeFile(image2)
+	if err != nil {
+		return false, -1, "", err
+	}
+
+	b1, b2 := img1.Bounds(), img2.Bounds()
+	width, height := max(b1.Dx(), b2.Dx()), max(b1.Dy(), b2.Dy())
+	heatmap := stdimage.NewRGBA(stdimage.Rect(0, 0, width, height))
+	fuzz := m.fuzz / 100
+
+	var squared [3]float64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			in1 := x < b1.Dx() && y < b1.Dy()
+			in2 := x < b2.Dx() && y < b2.Dy()
+			var c1, c2 [3]float64
+			if in1 {
+				c1 = channels(img1.At(b1.Min.X+x, b1.Min.Y+y))
+			}
+			if in2 {
+				c2 = channels(img2.At(b2.Min.X+x, b2.Min.Y+y))
+			}
+
+			differs := false
+			for i := range squared {
+				d := 1.0
+				if in1 && in2 {
+					d = math.Abs(c1[i] - c2[i])
+					if d <= fuzz {
+						d = 0
+					}
+				}
+				if d > 0 {
+					differs = true
+				}
+				squared[i] += d * d
+			}
+			heatmap.Set(x, y, heatmapColor(c1, differs))
+		}
+	}
+
+	psnr = math.Inf(1)
+	if pixels := float64(width * height); pixels > 0 {
+		for _, sum := range squared {
+			if sum > 0 {
+				psnr = min(psnr, 10*math.Log10(pixels/sum)/psnrScale)
+			}
+		}
+	}
+	isDifferent = psnr < threshold
+
+	if !isDifferent && !m.keepIdentical {
+		return false, psnr, "", nil
+	}
+	baseName := strings.TrimSuffix(filepath.Base(image1), filepath.Ext(image1))
+	diffPath = filepath.Join(outputDir, baseName+m.tempSuffix+m.diffExt)
+	if err := writePNG(diffPath, heatmap); err != nil {
+		return false, -1, "", err
+	}
+	return isDifferent, psnr, diffPath, nil
+}
+
+// channels returns the red, green and blue values of c scaled to [0, 1]
+func channels(c color.Color) [3]float64 {
+	r, g, b, _ := c.RGBA()
+	return [3]float64{float64(r) / 0xffff, float64(g) / 0xffff, float64(b) / 0xffff}
+}
+
+// heatmapColor is the diff image pixel: red where the images differ, else
+// the pixel of the first image faded towards white
+func heatmapColor(c [3]float64, differs bool) color.RGBA {
+	if differs {
+		return color.RGBA{R: 0xff, A: 0xff}
+	}
+	fade := func(v float64) uint8 { return uint8(0xff - (1-v)*0xff/4) }
+	return color.RGBA{R: fade(c[0]), G: fade(c[1]), B: fade(c[2]), A: 0xff}
+}
+
+// writePNG encodes img to path
+func writePNG(path string, img stdimage.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create diff image: %w", err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write diff image: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,93 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// DefaultEncoding is the converter output encoding assumed when
+// Options.Encoding is empty
+const DefaultEncoding = "utf-8"
+
+// lookupEncoding resolves an encoding name such as "utf-8", "shift_jis" or
+// "euc-jp" (WHATWG labels)
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %q", name)
+	}
+	return enc, nil
+}
+
+// CheckEncoding reports an error if name is not a known encoding
+func CheckEncoding(name string) error {
+	_, err := lookupEncoding(name)
+	return err
+}
+
+// isUTF8 reports whether name is a label of UTF-8
+func isUTF8(name string) bool {
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return false
+	}
+	canonical, err := htmlindex.Name(enc)
+	return err == nil && canonical == "utf-8"
+}
+
+// decodeOutput converts converter output in the given encoding to UTF-8.
+// Invalid sequences become U+FFFD, and a warning is returned if there were
+// any. Replacement characters the output really contains are not counted.
+func decodeOutput(out []byte, name string) (string, []string, error) {
+	var text string
+	var invalid int
+	if isUTF8(name) {
+		text, invalid = toValidUTF8(out)
+	} else {
+		enc, err := lookupEncoding(name)
+		if err != nil {
+			return "", nil, err
+		}
+		decoded, err := enc.NewDecoder().Bytes(out)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to decode converter output as %s: %w", name, err)
+		}
+		text = string(decoded)
+		invalid = strings.Count(text, string(utf8.RuneError))
+		// An encoding that can represent U+FFFD may carry real ones
+		if replacement, err := enc.NewEncoder().Bytes([]byte(string(utf8.RuneError))); err == nil && len(replacement) > 0 {
+			invalid -= bytes.Count(out, replacement)
+		}
+	}
+
+	var warnings []string
+	if invalid > 0 {
+		warnings = append(warnings, fmt.Sprintf("converter output has %d undecodable character(s) as %s; check the output encoding", invalid, name))
+	}
+	return text, warnings, nil
+}
+
+// toValidUTF8 replaces every invalid byte of b with U+FFFD and returns how
+// many there were
+func toValidUTF8(b []byte) (string, int) {
+	if utf8.Valid(b) {
+		return string(b), 0
+	}
+	var sb strings.Builder
+	sb.Grow(len(b))
+	invalid := 0
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError && size == 1 {
+			invalid++
+		}
+		sb.WriteRune(r)
+		b = b[size:]
+	}
+	return sb.String(), invalid
+}
@@ -0,0 +1,39 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeOutputCountsInvalidSequences(t *testing.T) {
+	tests := []struct {
+		name     string
+		out      []byte
+		encoding string
+		text     string
+		warning  string
+	}{
+		{name: "valid", out: []byte("abc"), encoding: "utf-8", text: "abc"},
+		{name: "real replacement character", out: []byte("a�b"), encoding: "utf-8", text: "a�b"},
+		{name: "run of invalid bytes", out: []byte("a\xff\xfe\xfdb"), encoding: "utf-8", text: "a���b", warning: "has 3 undecodable"},
+		{name: "invalid and real", out: []byte("�\xff"), encoding: "utf-8", text: "��", warning: "has 1 undecodable"},
+		{name: "shift_jis", out: []byte("\x82\xa0"), encoding: "shift_jis", text: "あ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, warnings, err := decodeOutput(tt.out, tt.encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != tt.text {
+				t.Errorf("text = %q, want %q", text, tt.text)
+			}
+			switch {
+			case tt.warning == "" && len(warnings) > 0:
+				t.Errorf("unexpected warnings %q", warnings)
+			case tt.warning != "" && (len(warnings) != 1 || !strings.Contains(warnings[0], tt.warning)):
+				t.Errorf("warnings = %q, want one containing %q", warnings, tt.warning)
+			}
+		})
+	}
+}
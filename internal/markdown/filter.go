@@ -0,0 +1,49 @@
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Filter replaces every match of a regular expression, e.g. to mask a
+// generated date or document ID before diffing
+type Filter struct {
+	Pattern     *regexp.Regexp
+	Replacement string // may refer to submatches as $1 or ${name}
+}
+
+// ParseFilter parses a "<regex>=<replacement>" rule. The rule splits at its
+// last "=", so the regex may contain "=" but the replacement may not.
+func ParseFilter(rule string) (Filter, error) {
+	i := strings.LastIndex(rule, "=")
+	if i <= 0 {
+		return Filter{}, fmt.Errorf("invalid filter %q (expected <regex>=<replacement>)", rule)
+	}
+	pattern, err := regexp.Compile(rule[:i])
+	if err != nil {
+		return Filter{}, fmt.Errorf("invalid filter %q: %w", rule, err)
+	}
+	return Filter{Pattern: pattern, Replacement: rule[i+1:]}, nil
+}
+
+// ParseFilters parses each rule with ParseFilter
+func ParseFilters(rules []string) ([]Filter, error) {
+	filters := make([]Filter, 0, len(rules))
+	for _, rule := range rules {
+		f, err := ParseFilter(rule)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// ApplyFilters runs content through filters in order
+func ApplyFilters(content string, filters []Filter) string {
+	for _, f := range filters {
+		content = f.Pattern.ReplaceAllString(content, f.Replacement)
+	}
+	return content
+}
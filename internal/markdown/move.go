@@ -0,0 +1,141 @@
+package markdown
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/shioshosho/diff-docx/internal/diff"
+)
+
+// MinMoveLines is the number of non-blank lines a block needs before it is
+// considered for move detection, so that short repeated lines are not
+// reported as moves.
+const MinMoveLines = 3
+
+// Move is a block of lines removed in one place and added unchanged in
+// another.
+type Move struct {
+	OldStart, OldEnd int    // 1-based line range in the first document
+	NewStart, NewEnd int    // 1-based line range in the second document
+	Lines            int    // non-blank lines in the block
+	First            string // first non-blank line, for display
+}
+
+// changeBlock is a run of removed or added lines. Blank context lines do
+// not interrupt a run, since paragraphs are separated by blank lines that
+// diff often keeps as context.
+type changeBlock struct {
+	lines   []string // non-blank lines, trimmed
+	lineNos []int    // 1-based line number of each entry in lines
+}
+
+func (b *changeBlock) add(line int, text string) {
+	if text = strings.TrimSpace(text); text != "" {
+		b.lines = append(b.lines, text)
+		b.lineNos = append(b.lineNos, line)
+	}
+}
+
+// indexLines returns the index at which sub occurs contiguously in lines, or -1.
+func indexLines(lines, sub []string) int {
+	for i := 0; i+len(sub) <= len(lines); i++ {
+		if slices.Equal(lines[i:i+len(sub)], sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// DetectMoves finds blocks of at least MinMoveLines non-blank lines that the
+// unified diff shows as removed in one place and added with identical
+// content elsewhere.
+func DetectMoves(unified string) []Move {
+	var removed, added []changeBlock
+
+	for _, hunk := range diff.ParseHunks(unified) {
+		oldLine, newLine := hunk.OldStart, hunk.NewStart
+		var del, ins changeBlock
+		flush := func() {
+			if len(del.lines) > 0 {
+				removed = append(removed, del)
+			}
+			if len(ins.lines) > 0 {
+				added = append(added, ins)
+			}
+			del, ins = changeBlock{}, changeBlock{}
+		}
+		for _, l := range hunk.Lines {
+			switch l[0] {
+			case '-':
+				del.add(oldLine, l[1:])
+				oldLine++
+			case '+':
+				ins.add(newLine, l[1:])
+				newLine++
+			default:
+				if strings.TrimSpace(l[1:]) != "" {
+					flush()
+				}
+				oldLine++
+				newLine++
+			}
+		}
+		flush()
+	}
+
+	// A moved block often shares a run with neighbouring edits, so match a
+	// removed block contained in an added one and vice versa.
+	var moves []Move
+	used := make([]bool, len(added))
+	for _, d := range removed {
+		for i, a := range added {
+			if used[i] {
+				continue
+			}
+			var oldNos, newNos []int
+			if len(d.lines) <= len(a.lines) {
+				at := indexLines(a.lines, d.lines)
+				if len(d.lines) < MinMoveLines || at < 0 {
+					continue
+				}
+				oldNos, newNos = d.lineNos, a.lineNos[at:at+len(d.lines)]
+			} else {
+				at := indexLines(d.lines, a.lines)
+				if len(a.lines) < MinMoveLines || at < 0 {
+					continue
+				}
+				oldNos, newNos = d.lineNos[at:at+len(a.lines)], a.lineNos
+			}
+			n := len(oldNos)
+			used[i] = true
+			moves = append(moves, Move{
+				OldStart: oldNos[0], OldEnd: oldNos[n-1],
+				NewStart: newNos[0], NewEnd: newNos[n-1],
+				Lines: n,
+				First: d.lines[slices.Index(d.lineNos, oldNos[0])],
+			})
+			break
+		}
+	}
+	return moves
+}
+
+// FormatMoves renders moves as a "## Moved Sections" section. It returns an
+// empty string when there are no moves.
+func FormatMoves(moves []Move) string {
+	if len(moves) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("## Moved Sections\n\n")
+	for _, m := range moves {
+		first := m.First
+		if r := []rune(first); len(r) > 60 {
+			first = string(r[:60]) + "…"
+		}
+		fmt.Fprintf(&b, "- `%s` (%d lines): lines %d-%d → lines %d-%d\n",
+			first, m.Lines, m.OldStart, m.OldEnd, m.NewStart, m.NewEnd)
+	}
+	return b.String()
+}
@@ -2,21 +2,28 @@ package markdown
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 
 	"github.com/shioshosho/diff-docx/internal/image"
+	"github.com/shioshosho/diff-docx/internal/retry"
+	"golang.org/x/text/unicode/norm"
 )
 
 // ProcessResult holds the markdown processing result
 type ProcessResult struct {
-	Content     string   // Processed markdown content
-	OutputPath  string   // Path to the processed markdown file
-	ImagePaths  []string // List of image paths referenced in the markdown
+	Content            string            // Processed markdown content
+	OutputPath         string            // Path to the processed markdown file
+	ImagePaths         []string          // List of image paths referenced in the markdown
+	ConversionWarnings []string          // Lines the converter wrote to stderr on success
+	AltTexts           map[string]string // Alt text of each image linked in the markdown, by image path
 }
 
 // mimeToExts maps MIME sub-types to file extensions found in word/media/
@@ -33,21 +40,101 @@ var mimeToExts = map[string][]string{
 	"vnd.ms-photo": {".wdp"},
 }
 
-// ConvertToMarkdown converts a docx file to markdown using markitdown
-func ConvertToMarkdown(docxPath string) (string, error) {
+// ErrConversionFailed is returned when the converter cannot turn a docx into markdown
+var ErrConversionFailed = errors.New("markdown conversion failed")
+
+// Options controls markdown conversion
+type Options struct {
+	Converter  string       // converter command: markitdown (default) or pandoc
+	Retry      retry.Policy // retry policy for the converter command
+	OutputPath string       // where to save the markdown; empty means <docx>.md beside the docx
+	Encoding   string       // encoding of the markitdown output (default: DefaultEncoding); pandoc writes UTF-8
+}
+
+// Converters lists the supported docx-to-markdown converters, in order of
+// preference for auto-selection
+var Converters = []string{"markitdown", "pandoc"}
+
+// SelectConverter resolves the converter name. An empty name or "auto"
+// picks the first converter in Converters that is on PATH, falling back to
+// markitdown so that the dependency check can report it as missing.
+func SelectConverter(name string) (string, error) {
+	if name != "" && name != "auto" {
+		if !slices.Contains(Converters, name) {
+			return "", fmt.Errorf("unknown converter %q (expected auto, markitdown, or pandoc)", name)
+		}
+		return name, nil
+	}
+	for _, c := range Converters {
+		if _, err := exec.LookPath(c); err == nil {
+			return c, nil
+		}
+	}
+	return Converters[0], nil
+}
+
+func converterCommand(converter, docxPath, encoding string) *exec.Cmd {
+	if converter == "pandoc" {
+		// pandoc always writes UTF-8
+		return exec.Command("pandoc", "--from", "docx", "--to", "gfm", "--wrap", "none", docxPath)
+	}
+	// markitdown writes in the locale's encoding unless Python is told otherwise
 	cmd := exec.Command("markitdown", docxPath)
+	cmd.Env = append(os.Environ(), "PYTHONIOENCODING="+encoding)
+	return cmd
+}
+
+// ConvertToMarkdown converts a docx file to markdown using the configured
+// converter. Any stderr output of a successful run is returned as warnings.
+func ConvertToMarkdown(docxPath string, opts Options) (string, []string, error) {
+	converter := opts.Converter
+	if converter == "" {
+		converter = Converters[0]
+	}
+	encoding := opts.Encoding
+	if encoding == "" {
+		encoding = DefaultEncoding
+	}
+	if err := CheckEncoding(encoding); err != nil {
+		return "", nil, err
+	}
+
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	newCmd := func() *exec.Cmd {
+		stdout.Reset()
+		stderr.Reset()
+		cmd := converterCommand(converter, docxPath, encoding)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		return cmd
+	}
+	retryable := func(err error) bool {
+		return retry.Transient(err, stderr.String())
+	}
+
+	if err := opts.Retry.Run(newCmd, retryable); err != nil {
+		return "", nil, fmt.Errorf("%w: %s failed: %w\nstderr: %s", ErrConversionFailed, converter, err, stderr.String())
+	}
 
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("markitdown failed: %w\nstderr: %s", err, stderr.String())
+	var warnings []string
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			warnings = append(warnings, line)
+		}
 	}
 
-	return stdout.String(), nil
+	// Encoding only applies to markitdown; pandoc always writes UTF-8
+	if converter == "pandoc" {
+		encoding = DefaultEncoding
+	}
+	content, decodeWarnings, err := decodeOutput(stdout.Bytes(), encoding)
+	if err != nil {
+		return "", nil, err
+	}
+	return content, append(warnings, decodeWarnings...), nil
 }
 
-// groupImagesByExt groups extracted images by extension, sorted by filename.
+// groupImagesByExt groups extracted images by extension, sorted by media name.
 func groupImagesByExt(images map[string]string) map[string][]string {
 	groups := make(map[string][]string)
 	extNames := make(map[string][]string)
@@ -142,17 +229,80 @@ func ReplaceBase64Images(content string, images map[string]string) (string, erro
 	return result.String(), nil
 }
 
+// ReplaceMediaLinks rewrites references to media/<name>, as emitted by
+// pandoc, to the extracted image paths.
+func ReplaceMediaLinks(content string, images map[string]string) string {
+	for name, path := range images {
+		content = strings.ReplaceAll(content, "](media/"+name+")", "]("+path+")")
+		content = strings.ReplaceAll(content, `src="media/`+name+`"`, `src="`+path+`"`)
+	}
+	return content
+}
+
+var imageLink = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+
+// altTexts returns the alt text of each of the images linked from content,
+// keyed by image path. An image linked more than once keeps its first alt
+// text.
+func altTexts(content string, images map[string]string) map[string]string {
+	paths := make(map[string]bool, len(images))
+	for _, path := range images {
+		paths[path] = true
+	}
+	alts := make(map[string]string)
+	for _, m := range imageLink.FindAllStringSubmatch(content, -1) {
+		if _, seen := alts[m[2]]; paths[m[2]] && !seen {
+			alts[m[2]] = m[1]
+		}
+	}
+	return alts
+}
+
+// referencesAnyImage reports whether content links to at least one of the images.
+func referencesAnyImage(content string, images map[string]string) bool {
+	for _, path := range images {
+		if strings.Contains(content, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendImageSection appends a "## Images" section linking every image, for
+// converters that drop images instead of inlining them. This keeps the media
+// visible to NormalizeForDiff and therefore to the text diff.
+func appendImageSection(content string, images map[string]string) string {
+	names := make([]string, 0, len(images))
+	for name := range images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(content, "\n"))
+	b.WriteString("\n\n## Images\n\n")
+	for _, name := range names {
+		b.WriteString(fmt.Sprintf("![%s](%s)\n\n", name, images[name]))
+	}
+	return b.String()
+}
+
 // BuildPathMapping creates path normalization maps from image match results.
-// For matched (identical content) pairs, both docs map to the same canonical name.
+// For matched (identical content) pairs, both docs map to the same canonical name:
+// doc1's name, or shared/imageN.ext numbered in match order if shared is set.
 // For different/only-in-one, paths are prefixed with the docx basename to differentiate.
-func BuildPathMapping(matchResult *image.MatchResult, doc1Base, doc2Base string) (map1, map2 map[string]string) {
+func BuildPathMapping(matchResult *image.MatchResult, doc1Base, doc2Base string, shared bool) (map1, map2 map[string]string) {
 	map1 = make(map[string]string)
 	map2 = make(map[string]string)
 
-	// Matched pairs: both map to same canonical name (doc1's name)
-	for _, pair := range matchResult.Matched {
-		map1[pair.Image1.Path] = pair.Image1.Name
-		map2[pair.Image2.Path] = pair.Image1.Name
+	// Matched pairs: both map to same canonical name
+	for i, pair := range matchResult.Matched {
+		name := pair.Image1.Name
+		if shared {
+			name = fmt.Sprintf("shared/image%d%s", i+1, strings.ToLower(filepath.Ext(name)))
+		}
+		map1[pair.Image1.Path] = name
+		map2[pair.Image2.Path] = name
 	}
 
 	// Different pairs: prefix with docx basename
@@ -169,10 +319,12 @@ func BuildPathMapping(matchResult *image.MatchResult, doc1Base, doc2Base string)
 		map2[img.Path] = doc2Base + "/" + img.Name
 	}
 
-	// Skipped: use plain filename
-	for _, img := range matchResult.Skipped {
-		map1[img.Path] = img.Name
-		map2[img.Path] = img.Name
+	// Skipped and broken: use plain filename
+	for _, list := range [][]image.ImageInfo{matchResult.Skipped, matchResult.Broken} {
+		for _, img := range list {
+			map1[img.Path] = img.Name
+			map2[img.Path] = img.Name
+		}
 	}
 
 	return map1, map2
@@ -188,22 +340,77 @@ func NormalizeForDiff(content string, pathMapping map[string]string) string {
 	return result
 }
 
-// virtualDir returns a CWD-relative path derived from the docx path.
-// e.g. docs/filename.docx (CWD=$HOME/proj) -> ./docs/filename
-func virtualDir(docxPath string) string {
+// TrimEdges drops a leading UTF-8 byte order mark and the blank lines at
+// the start and end of content, and ends non-empty content with exactly one
+// newline, so that converter noise at the edges does not show as a change.
+func TrimEdges(content string) string {
+	content = strings.TrimPrefix(content, "\uFEFF")
+	lines := strings.Split(content, "\n")
+	start, end := 0, len(lines)
+	for start < end && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	for end > start && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	if start == end {
+		return ""
+	}
+	return strings.Join(lines[start:end], "\n") + "\n"
+}
+
+// NormalizeUnicode converts content to Unicode NFC so that precomposed and
+// decomposed forms of the same character compare equal.
+func NormalizeUnicode(content string) string {
+	return norm.NFC.String(content)
+}
+
+// SanitizeControl makes control characters other than tab and newline
+// visible: C0 characters and DEL become their Unicode control pictures (a
+// form feed becomes ␌) and C1 characters become <U+0085>-style escapes. The
+// carriage return of a CRLF line ending is dropped.
+func SanitizeControl(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n':
+			return r
+		case r < 0x20:
+			return 0x2400 + r
+		case r == 0x7f:
+			return '␡'
+		}
+		return r
+	}, c1Escapes.Replace(content))
+}
+
+// c1Escapes writes the C1 control characters as <U+0080> to <U+009F>
+var c1Escapes = func() *strings.Replacer {
+	var pairs []string
+	for r := rune(0x80); r <= 0x9f; r++ {
+		pairs = append(pairs, string(r), fmt.Sprintf("<U+%04X>", r))
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// virtualDir returns a path derived from the docx path, relative to baseDir.
+// e.g. docs/filename.docx (baseDir=$HOME/proj) -> ./docs/filename
+// and (baseDir=$HOME/proj/diff) -> ../docs/filename
+func virtualDir(docxPath, baseDir string) string {
+	fallback := "./" + strings.TrimSuffix(docxPath, filepath.Ext(docxPath))
 	absPath, err := filepath.Abs(docxPath)
 	if err != nil {
-		return "./" + strings.TrimSuffix(docxPath, filepath.Ext(docxPath))
+		return fallback
 	}
-	cwd, err := os.Getwd()
+	absBase, err := filepath.Abs(baseDir)
 	if err != nil {
-		return "./" + strings.TrimSuffix(docxPath, filepath.Ext(docxPath))
+		return fallback
 	}
-	relPath, err := filepath.Rel(cwd, absPath)
+	relPath, err := filepath.Rel(absBase, absPath)
 	if err != nil {
-		return "./" + strings.TrimSuffix(docxPath, filepath.Ext(docxPath))
+		return fallback
 	}
-	dir := strings.TrimSuffix(relPath, filepath.Ext(relPath))
+	dir := filepath.ToSlash(strings.TrimSuffix(relPath, filepath.Ext(relPath)))
 	if !strings.HasPrefix(dir, ".") {
 		dir = "./" + dir
 	}
@@ -213,8 +420,8 @@ func virtualDir(docxPath string) string {
 // ProcessMarkdown converts docx to markdown and replaces image references.
 // Content keeps temp paths (for internal use like NormalizeForDiff).
 // The saved md file has virtual relative paths for readability.
-func ProcessMarkdown(docxPath string, images map[string]string, tempDir string) (*ProcessResult, error) {
-	content, err := ConvertToMarkdown(docxPath)
+func ProcessMarkdown(docxPath string, images map[string]string, tempDir string, opts Options) (*ProcessResult, error) {
+	content, warnings, err := ConvertToMarkdown(docxPath, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -223,6 +430,10 @@ func ProcessMarkdown(docxPath string, images map[string]string, tempDir string)
 	if err != nil {
 		return nil, err
 	}
+	processedContent = ReplaceMediaLinks(processedContent, images)
+	if len(images) > 0 && !referencesAnyImage(processedContent, images) {
+		processedContent = appendImageSection(processedContent, images)
+	}
 
 	absDocxPath, err := filepath.Abs(docxPath)
 	if err != nil {
@@ -230,9 +441,18 @@ func ProcessMarkdown(docxPath string, images map[string]string, tempDir string)
 	}
 	baseName := strings.TrimSuffix(filepath.Base(absDocxPath), filepath.Ext(absDocxPath))
 	outputPath := filepath.Join(filepath.Dir(absDocxPath), baseName+".md")
+	linkBase := "."
+	if opts.OutputPath != "" {
+		outputPath = opts.OutputPath
+		linkBase = filepath.Dir(outputPath)
+		if err := os.MkdirAll(linkBase, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create markdown directory: %w", err)
+		}
+	}
 
-	// For the saved file, replace temp paths with virtual relative paths
-	vDir := virtualDir(docxPath)
+	// For the saved file, replace temp paths with virtual paths relative to
+	// the working directory, or to the markdown file when OutputPath is set
+	vDir := virtualDir(docxPath, linkBase)
 	fileContent := strings.ReplaceAll(processedContent, tempDir, vDir)
 
 	if err := os.WriteFile(outputPath, []byte(fileContent), 0644); err != nil {
@@ -245,8 +465,10 @@ func ProcessMarkdown(docxPath string, images map[string]string, tempDir string)
 	}
 
 	return &ProcessResult{
-		Content:    processedContent, // temp paths preserved for NormalizeForDiff
-		OutputPath: outputPath,
-		ImagePaths: imagePaths,
+		Content:            processedContent, // temp paths preserved for NormalizeForDiff
+		OutputPath:         outputPath,
+		ImagePaths:         imagePaths,
+		ConversionWarnings: warnings,
+		AltTexts:           altTexts(processedContent, images),
 	}, nil
 }
@@ -0,0 +1,49 @@
+package markdown
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrimEdges(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "BOM", content: "\uFEFF# Title\n\nBody\n", want: "# Title\n\nBody\n"},
+		{name: "BOM before blank lines", content: "\uFEFF\n\n# Title\n", want: "# Title\n"},
+		{name: "leading and trailing blank lines", content: "\n  \n# Title\n\nBody\n\n\t\n", want: "# Title\n\nBody\n"},
+		{name: "CRLF blank lines", content: "\r\n# Title\r\n\r\n", want: "# Title\r\n"},
+		{name: "missing final newline", content: "Body", want: "Body\n"},
+		{name: "inner blank lines kept", content: "a\n\n\nb\n", want: "a\n\n\nb\n"},
+		{name: "all blank", content: "\n \n\t\n", want: ""},
+		{name: "BOM only", content: "\uFEFF\n", want: ""},
+		{name: "empty", content: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TrimEdges(tt.content); got != tt.want {
+				t.Errorf("TrimEdges(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertToMarkdownPandocIgnoresEncoding(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\nprintf '\\343\\201\\202\\n'\n" // "あ" in UTF-8
+	if err := os.WriteFile(filepath.Join(dir, "pandoc"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	content, warnings, err := ConvertToMarkdown("in.docx", Options{Converter: "pandoc", Encoding: "shift_jis"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "あ\n" || len(warnings) > 0 {
+		t.Errorf("ConvertToMarkdown() = %q, %q; want %q without warnings", content, warnings, "あ\n")
+	}
+}
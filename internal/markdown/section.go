@@ -0,0 +1,130 @@
+package markdown
+
+import "strings"
+
+// parseHeading returns the level and title of an ATX heading line such as
+// "## 3. Pricing ##", or level 0 if line is not a heading.
+func parseHeading(line string) (level int, title string) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return 0, ""
+	}
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0, ""
+	}
+	rest := trimmed[level:]
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return 0, ""
+	}
+	title = strings.TrimSpace(rest)
+	if closing := strings.TrimRight(title, "#"); closing != title && (closing == "" || strings.HasSuffix(closing, " ")) {
+		title = strings.TrimSpace(closing)
+	}
+	return level, title
+}
+
+// isFence reports whether line opens or closes a fenced code block.
+func isFence(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// Section returns the part of content from the heading titled title up to
+// the next heading of the same or a higher level. Headings inside fenced
+// code blocks are ignored. It returns false if no heading matches.
+func Section(content, title string) (string, bool) {
+	title = strings.TrimSpace(title)
+	lines := strings.SplitAfter(content, "\n")
+
+	start, level := -1, 0
+	inFence := false
+	for i, line := range lines {
+		if isFence(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		l, t := parseHeading(strings.TrimRight(line, "\r\n"))
+		if l == 0 {
+			continue
+		}
+		if start < 0 {
+			if t == title {
+				start, level = i, l
+			}
+			continue
+		}
+		if l <= level {
+			return strings.Join(lines[start:i], ""), true
+		}
+	}
+	if start < 0 {
+		return "", false
+	}
+	return strings.Join(lines[start:], ""), true
+}
+
+// EnclosingHeading returns the title of the last heading at or before the
+// 1-based line number in content, or "" if there is none.
+func EnclosingHeading(content string, line int) string {
+	title := ""
+	inFence := false
+	for i, l := range strings.Split(content, "\n") {
+		if i >= line {
+			break
+		}
+		if isFence(l) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if level, t := parseHeading(strings.TrimRight(l, "\r")); level > 0 {
+			title = t
+		}
+	}
+	return title
+}
+
+// ChangedSections reports for each line of content whether it lies in a
+// changed section: the lines from a heading up to the next heading of any
+// level, or before the first heading, of which at least one 1-based line
+// number is in changed. Headings inside fenced code blocks are ignored.
+func ChangedSections(content string, changed map[int]bool) []bool {
+	lines := strings.Split(content, "\n")
+	inChanged := make([]bool, len(lines))
+	start := 0
+	mark := func(end int) {
+		for i := start; i < end; i++ {
+			if changed[i+1] {
+				for j := start; j < end; j++ {
+					inChanged[j] = true
+				}
+				return
+			}
+		}
+	}
+
+	inFence := false
+	for i, l := range lines {
+		if isFence(l) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if level, _ := parseHeading(strings.TrimRight(l, "\r")); level > 0 && i > start {
+			mark(i)
+			start = i
+		}
+	}
+	mark(len(lines))
+	return inChanged
+}
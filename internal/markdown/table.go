@@ -0,0 +1,185 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Table is a GFM pipe table parsed from markdown
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// isTableLine reports whether a line looks like a GFM table row.
+func isTableLine(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "|")
+}
+
+// isSeparatorLine reports whether a line is a GFM header separator like |---|:--:|.
+func isSeparatorLine(line string) bool {
+	cells := splitTableRow(line)
+	if len(cells) == 0 {
+		return false
+	}
+	for _, cell := range cells {
+		cell = strings.Trim(cell, ":")
+		if cell == "" || strings.Trim(cell, "-") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// splitTableRow splits a table row into trimmed cells, honoring escaped pipes.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) && line[i+1] == '|' {
+			cell.WriteByte('|')
+			i++
+			continue
+		}
+		if line[i] == '|' {
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+			continue
+		}
+		cell.WriteByte(line[i])
+	}
+	cells = append(cells, strings.TrimSpace(cell.String()))
+	return cells
+}
+
+// ParseTables returns the GFM pipe tables found in content, in document order.
+func ParseTables(content string) []Table {
+	lines := strings.Split(content, "\n")
+	var tables []Table
+
+	for i := 0; i+1 < len(lines); i++ {
+		if !isTableLine(lines[i]) || !isSeparatorLine(lines[i+1]) {
+			continue
+		}
+		table := Table{Header: splitTableRow(lines[i])}
+		j := i + 2
+		for ; j < len(lines) && isTableLine(lines[j]); j++ {
+			table.Rows = append(table.Rows, splitTableRow(lines[j]))
+		}
+		tables = append(tables, table)
+		i = j - 1
+	}
+
+	return tables
+}
+
+// keyedRows indexes rows by their first cell. Repeated keys get an occurrence
+// suffix so that every row stays addressable.
+func keyedRows(rows [][]string) (keys []string, byKey map[string][]string) {
+	byKey = make(map[string][]string)
+	seen := make(map[string]int)
+	for _, row := range rows {
+		key := ""
+		if len(row) > 0 {
+			key = row[0]
+		}
+		seen[key]++
+		if seen[key] > 1 {
+			key = fmt.Sprintf("%s (#%d)", key, seen[key])
+		}
+		keys = append(keys, key)
+		byKey[key] = row
+	}
+	return keys, byKey
+}
+
+func cellAt(row []string, i int) string {
+	if i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+func columnName(header []string, i int) string {
+	if name := cellAt(header, i); name != "" {
+		return name
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
+
+// diffTable lists the cell-level changes between two tables, aligning rows
+// by their first column.
+func diffTable(t1, t2 Table) []string {
+	var changes []string
+
+	if strings.Join(t1.Header, "|") != strings.Join(t2.Header, "|") {
+		changes = append(changes, fmt.Sprintf("Columns: `%s` → `%s`",
+			strings.Join(t1.Header, " | "), strings.Join(t2.Header, " | ")))
+	}
+
+	keys1, rows1 := keyedRows(t1.Rows)
+	keys2, rows2 := keyedRows(t2.Rows)
+
+	for _, key := range keys1 {
+		row1 := rows1[key]
+		row2, ok := rows2[key]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("Row `%s` removed", key))
+			continue
+		}
+		cols := max(len(row1), len(row2))
+		for c := 1; c < cols; c++ {
+			before, after := cellAt(row1, c), cellAt(row2, c)
+			if before != after {
+				changes = append(changes, fmt.Sprintf("Row `%s`, column `%s`: `%s` → `%s`",
+					key, columnName(t2.Header, c), before, after))
+			}
+		}
+	}
+	for _, key := range keys2 {
+		if _, ok := rows1[key]; !ok {
+			changes = append(changes, fmt.Sprintf("Row `%s` added", key))
+		}
+	}
+
+	return changes
+}
+
+// DiffTables compares the GFM tables of two markdown documents, pairing them
+// by order, and renders the cell-level changes as a "## Table Changes"
+// section. It returns an empty string when no table changed.
+func DiffTables(content1, content2 string) string {
+	tables1 := ParseTables(content1)
+	tables2 := ParseTables(content2)
+
+	var b strings.Builder
+	for i := 0; i < max(len(tables1), len(tables2)); i++ {
+		switch {
+		case i >= len(tables2):
+			fmt.Fprintf(&b, "### Table %d (only in first document)\n\n", i+1)
+		case i >= len(tables1):
+			fmt.Fprintf(&b, "### Table %d (only in second document)\n\n", i+1)
+		default:
+			changes := diffTable(tables1[i], tables2[i])
+			if len(changes) == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "### Table %d\n\n", i+1)
+			for _, change := range changes {
+				fmt.Fprintf(&b, "- %s\n", change)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+	return "## Table Changes\n\n" + b.String()
+}
@@ -0,0 +1,61 @@
+package markdown
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// WordsPerMinute is the reading speed ReadingMinutes assumes
+const WordsPerMinute = 230
+
+var (
+	imageRef = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	linkRef  = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+)
+
+// WordCount counts the words of markdown content, leaving out image
+// references, link targets and fenced code blocks. A word is a run of
+// letters or digits; each Chinese or Japanese character counts as one
+// word, since those scripts do not separate words with spaces.
+func WordCount(content string) int {
+	n := 0
+	inFence := false
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") || strings.HasPrefix(strings.TrimSpace(line), "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		line = imageRef.ReplaceAllString(line, "")
+		line = linkRef.ReplaceAllString(line, "$1")
+
+		inWord := false
+		for _, r := range line {
+			switch {
+			case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+				n++
+				inWord = false
+			case unicode.IsLetter(r) || unicode.IsDigit(r):
+				if !inWord {
+					n++
+				}
+				inWord = true
+			case r == '\'' || r == '’':
+				// Keep contractions such as "don't" one word
+			default:
+				inWord = false
+			}
+		}
+	}
+	return n
+}
+
+// ReadingMinutes estimates the time to read words at WordsPerMinute,
+// rounded to a tenth of a minute
+func ReadingMinutes(words int) float64 {
+	return math.Round(float64(words)/WordsPerMinute*10) / 10
+}
@@ -19,6 +19,8 @@ type Bar struct {
 	total   int
 	current int
 	width   int
+	prefix  string
+	lastLen int // length in runes of the last rendered line
 }
 
 // New creates a new progress bar with the given total steps.
@@ -26,31 +28,119 @@ func New(total int) *Bar {
 	return &Bar{total: total, width: barWidth()}
 }
 
+// SetPrefix sets a label rendered before the bar, e.g. "file 3/20: report.docx".
+// It is used to show overall position when several comparisons run in sequence.
+func (b *Bar) SetPrefix(prefix string) {
+	if b == nil {
+		return
+	}
+	b.prefix = prefix
+}
+
 // Advance increments the progress and renders with the given description.
+// A nil Bar is valid and renders nothing.
 func (b *Bar) Advance(desc string) {
+	if b == nil {
+		return
+	}
 	b.current++
 	b.render(desc)
 }
 
+// Set moves the progress to step current and renders with the given description.
+func (b *Bar) Set(current int, desc string) {
+	if b == nil {
+		return
+	}
+	b.current = current
+	b.render(desc)
+}
+
+// Sub renders fractional progress within the current step without advancing
+// it, e.g. "5/7 Matching images... (12/48)".
+func (b *Bar) Sub(done, total int, desc string) {
+	if b == nil || total <= 0 {
+		return
+	}
+	pos := float64(b.current) + float64(done)/float64(total)
+	if pos > float64(b.total) {
+		pos = float64(b.total)
+	}
+	b.renderAt(pos, fmt.Sprintf("%s (%d/%d)", desc, done, total))
+}
+
 // Done clears the progress bar line.
 func (b *Bar) Done() {
-	fmt.Fprintf(os.Stderr, "\r%s\r", strings.Repeat(" ", b.width+40))
+	if b == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\r%s\r", strings.Repeat(" ", b.lastLen))
+	b.lastLen = 0
+}
+
+// Abort leaves the bar on screen at its current position, marked as aborted
+// for the given reason, and ends the line.
+func (b *Bar) Abort(reason string) {
+	if b == nil {
+		return
+	}
+	b.render("aborted: " + reason)
+	fmt.Fprintln(os.Stderr)
+	b.lastLen = 0
 }
 
 func (b *Bar) render(desc string) {
-	pct := float64(b.current) / float64(b.total)
+	b.renderAt(float64(b.current), desc)
+}
+
+func (b *Bar) renderAt(pos float64, desc string) {
+	// Re-measure on every render so that the bar follows terminal resizes
+	cols := termWidth()
+	b.width = barWidthFor(cols)
+
+	pct := pos / float64(b.total)
 	filled := int(pct * float64(b.width))
 	if filled > b.width {
 		filled = b.width
 	}
 
 	bar := strings.Repeat(fillChar, filled) + strings.Repeat(emptyChar, b.width-filled)
-	fmt.Fprintf(os.Stderr, "\r%3.0f%%|%s| %d/%d %s", pct*100, bar, b.current, b.total, desc)
+	prefix := ""
+	if b.prefix != "" {
+		prefix = b.prefix + " "
+	}
+	line := fmt.Sprintf("%s%3.0f%%|%s| %d/%d %s", prefix, pct*100, bar, b.current, b.total, desc)
+
+	// Keep the line shorter than the terminal so it never wraps, and blank
+	// out whatever is left of a longer previous line
+	runes := []rune(line)
+	if cols > 0 && len(runes) >= cols {
+		runes = runes[:cols-1]
+	}
+	pad := ""
+	if len(runes) < b.lastLen {
+		pad = strings.Repeat(" ", b.lastLen-len(runes))
+	}
+	fmt.Fprintf(os.Stderr, "\r%s%s", string(runes), pad)
+	b.lastLen = len(runes)
+}
+
+// termWidth returns the column count of the terminal on stderr, or 0 if
+// stderr is not a terminal
+func termWidth() int {
+	if w, _, err := term.GetSize(int(os.Stderr.Fd())); err == nil {
+		return w
+	}
+	return 0
 }
 
 func barWidth() int {
-	if w, _, err := term.GetSize(int(os.Stderr.Fd())); err == nil && w > 80 {
-		return w / 3
+	return barWidthFor(termWidth())
+}
+
+func barWidthFor(cols int) int {
+	if cols > 80 {
+		return cols / 3
 	}
 	return defaultBarWidth
 }
@@ -0,0 +1,157 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultBackoff is the delay before the first retry
+const DefaultBackoff = 500 * time.Millisecond
+
+// killWaitDelay bounds how long a killed command's output is still read
+const killWaitDelay = 500 * time.Millisecond
+
+// Policy controls how failed external commands are retried
+type Policy struct {
+	Retries int                              // retries after the first attempt
+	Backoff time.Duration                    // delay before the first retry, doubled for each further retry (default: DefaultBackoff)
+	Logf    func(format string, args ...any) // optional debug logger
+	Limiter Limiter                          // optional bound on commands running at once, shared across policies
+	Context context.Context                  // optional; when it is done, running commands are killed and no retry starts
+}
+
+// Err returns the error of the policy's context once it is done, and nil
+// otherwise or without a context
+func (p Policy) Err() error {
+	if p.Context == nil {
+		return nil
+	}
+	return p.Context.Err()
+}
+
+// Limiter bounds the number of external commands running at once. A nil
+// Limiter imposes no bound.
+type Limiter chan struct{}
+
+// NewLimiter returns a Limiter that allows n concurrent commands
+func NewLimiter(n int) Limiter {
+	return make(Limiter, max(n, 1))
+}
+
+func (l Limiter) acquire() {
+	if l != nil {
+		l <- struct{}{}
+	}
+}
+
+func (l Limiter) release() {
+	if l != nil {
+		<-l
+	}
+}
+
+// transientMarkers are stderr fragments that indicate a failure worth retrying
+var transientMarkers = []string{
+	"resource temporarily unavailable",
+	"too many open files",
+	"cannot allocate memory",
+	"memoryerror",
+	"memory allocation failed",
+	"cache resources exhausted",
+	"timed out",
+	"timeout",
+	"device or resource busy",
+	"interrupted system call",
+}
+
+// Transient reports whether a command failure looks non-deterministic.
+// Missing executables and files are never transient; a process killed by a
+// signal, or one whose stderr reports resource exhaustion, is.
+func Transient(err error, stderr string) bool {
+	if err == nil || errors.Is(err, exec.ErrNotFound) {
+		return false
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == -1 {
+		return true
+	}
+	lower := strings.ToLower(stderr)
+	for _, marker := range transientMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run runs the command built by newCmd, retrying while retryable reports the
+// error as worth another attempt. newCmd is called for every attempt because
+// an exec.Cmd cannot be reused, so it should also reset any output buffers.
+func (p Policy) Run(newCmd func() *exec.Cmd, retryable func(err error) bool) error {
+	delay := p.Backoff
+	if delay <= 0 {
+		delay = DefaultBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := p.Err(); err != nil {
+			return err
+		}
+		cmd := newCmd()
+		p.Limiter.acquire()
+		err := p.run(cmd)
+		p.Limiter.release()
+		if ctxErr := p.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err == nil || attempt >= p.Retries || !retryable(err) {
+			return err
+		}
+		if p.Logf != nil {
+			p.Logf("retrying %s in %s (attempt %d/%d): %v", cmd.Path, delay, attempt+1, p.Retries, err)
+		}
+		if !p.sleep(delay) {
+			return p.Err()
+		}
+		delay *= 2
+	}
+}
+
+// run runs cmd, killing it when the policy's context is done
+func (p Policy) run(cmd *exec.Cmd) error {
+	if p.Context == nil {
+		return cmd.Run()
+	}
+	// Do not wait long for output pipes held open by the killed command's
+	// children
+	if cmd.WaitDelay == 0 {
+		cmd.WaitDelay = killWaitDelay
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(p.Context, func() {
+		cmd.Process.Kill()
+	})
+	defer stop()
+	return cmd.Wait()
+}
+
+// sleep waits for d and reports false if the policy's context is done first
+func (p Policy) sleep(d time.Duration) bool {
+	if p.Context == nil {
+		time.Sleep(d)
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-p.Context.Done():
+		return false
+	}
+}
@@ -0,0 +1,256 @@
+package source
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Document is a resolved input document
+type Document struct {
+	Path      string // local path to read the document from
+	CleanupFn func() // Function to cleanup any temporary copy
+}
+
+// Options controls how arguments are resolved
+type Options struct {
+	Timeout time.Duration   // limit for downloading an http(s) URL; 0 means no limit
+	Context context.Context // optional; cancels a download in progress
+}
+
+// NullArg is the argument that stands for an empty document
+const NullArg = "/dev/null"
+
+// Resolve maps a command-line argument to a local file. Existing files are
+// used as-is; NullArg becomes an empty document, http(s) URLs are downloaded
+// and arguments of the form <rev>:<path> are read from git.
+func Resolve(arg string, opts Options) (*Document, error) {
+	if arg == NullArg {
+		return Empty()
+	}
+
+	if _, err := os.Stat(arg); err == nil {
+		return &Document{Path: arg, CleanupFn: func() {}}, nil
+	}
+
+	if isURL(arg) {
+		return fromURL(arg, opts)
+	}
+
+	if rev, path, ok := splitGitRef(arg); ok {
+		return fromGit(rev, path)
+	}
+
+	return &Document{Path: arg, CleanupFn: func() {}}, nil
+}
+
+// splitGitRef splits a <rev>:<path> argument. Windows volume names such as
+// C:\ are not treated as revisions.
+func splitGitRef(arg string) (rev, path string, ok bool) {
+	if filepath.VolumeName(arg) != "" {
+		return "", "", false
+	}
+	idx := strings.Index(arg, ":")
+	if idx < 0 || idx == len(arg)-1 {
+		return "", "", false
+	}
+	return arg[:idx], arg[idx+1:], true
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._~^-]+`)
+
+// fromGit writes the blob at rev:path to a temporary file named after the
+// revision and path so that both sides of a comparison stay distinguishable.
+func fromGit(rev, path string) (*Document, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is required to read %s:%s", rev, path)
+	}
+
+	object := rev + ":" + path
+	var stderr strings.Builder
+	check := exec.Command("git", "cat-file", "-e", object)
+	check.Stderr = &stderr
+	if err := check.Run(); err != nil {
+		return nil, fmt.Errorf("git object %s does not exist: %s", object, strings.TrimSpace(stderr.String()))
+	}
+
+	data, err := exec.Command("git", "show", object).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git show %s failed: %w", object, err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "ddx-git-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	cleanupFn := func() {
+		os.RemoveAll(tempDir)
+	}
+
+	name := filepath.Base(path)
+	if rev != "" {
+		name = unsafeNameChars.ReplaceAllString(rev, "_") + "-" + name
+	}
+	tempPath := filepath.Join(tempDir, name)
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		cleanupFn()
+		return nil, fmt.Errorf("failed to write %s: %w", object, err)
+	}
+
+	return &Document{Path: tempPath, CleanupFn: cleanupFn}, nil
+}
+
+// isURL reports whether arg is an http or https URL
+func isURL(arg string) bool {
+	lower := strings.ToLower(arg)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// fromURL downloads rawURL to a temporary file named by downloadName.
+// Proxies are taken from HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+func fromURL(rawURL string, opts Options) (*Document, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", rawURL, err)
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", redact(u), err)
+	}
+	client := &http.Client{Timeout: opts.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The *url.Error message quotes the whole URL, signature included
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			uerr.URL = redact(u)
+		}
+		return nil, fmt.Errorf("failed to download %s: %w", redact(u), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", redact(u), resp.Status)
+	}
+
+	tempDir, err := os.MkdirTemp("", "ddx-url-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	cleanupFn := func() {
+		os.RemoveAll(tempDir)
+	}
+
+	tempPath := filepath.Join(tempDir, downloadName(u, resp.Header))
+	f, err := os.Create(tempPath)
+	if err != nil {
+		cleanupFn()
+		return nil, fmt.Errorf("failed to create %s: %w", tempPath, err)
+	}
+	_, err = io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanupFn()
+		return nil, fmt.Errorf("failed to download %s: %w", redact(u), err)
+	}
+
+	return &Document{Path: tempPath, CleanupFn: cleanupFn}, nil
+}
+
+// downloadName names a downloaded document after the Content-Disposition
+// filename or else the last path segment of u, with ".docx" appended when
+// the name lacks it, as for https://host/download?id=1
+func downloadName(u *url.URL, header http.Header) string {
+	name := path.Base(u.Path)
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = path.Base(strings.ReplaceAll(params["filename"], `\`, "/"))
+	}
+	name = unsafeNameChars.ReplaceAllString(name, "_")
+	if name == "" || name == "." || name == ".." || name == "_" {
+		name = "download"
+	}
+	if !strings.HasSuffix(strings.ToLower(name), ".docx") {
+		name += ".docx"
+	}
+	return name
+}
+
+// redact drops the query string and user info, which often carry
+// credentials such as presigned URL signatures, from u for messages
+func redact(u *url.URL) string {
+	r := *u
+	r.User = nil
+	r.RawQuery = ""
+	r.Fragment = ""
+	return r.String()
+}
+
+// emptyDocxParts are the archive entries of a Word document without content
+var emptyDocxParts = []struct{ name, content string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/></Relationships>`},
+	{"word/document.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body/></w:document>`},
+}
+
+// Empty writes a Word document without text or images to a temporary file
+// named empty.docx, for comparing a document against nothing.
+func Empty() (*Document, error) {
+	tempDir, err := os.MkdirTemp("", "ddx-empty-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	cleanupFn := func() {
+		os.RemoveAll(tempDir)
+	}
+
+	tempPath := filepath.Join(tempDir, "empty.docx")
+	if err := writeEmptyDocx(tempPath); err != nil {
+		cleanupFn()
+		return nil, fmt.Errorf("failed to write empty document: %w", err)
+	}
+	return &Document{Path: tempPath, CleanupFn: cleanupFn}, nil
+}
+
+func writeEmptyDocx(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, part := range emptyDocxParts {
+		w, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,961 @@
+// Package ddx compares two Word documents and produces a markdown diff plus
+// image comparison artifacts. It is the library behind the ddx command.
+package ddx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shioshosho/diff-docx/internal/diff"
+	"github.com/shioshosho/diff-docx/internal/docx"
+	"github.com/shioshosho/diff-docx/internal/image"
+	"github.com/shioshosho/diff-docx/internal/markdown"
+	"github.com/shioshosho/diff-docx/internal/retry"
+)
+
+// DefaultOutputDir is the output directory used when Options.OutputDir is empty
+const DefaultOutputDir = "diff"
+
+// DefaultMaxImages is a per-document image limit suited to untrusted
+// input; image matching is quadratic in the number of images per format
+const DefaultMaxImages = 10000
+
+// Steps is the number of pipeline steps reported through Options.Progress
+const Steps = 7
+
+// stageNames names the timed stage of each pipeline step
+var stageNames = [Steps]string{"extract", "extract", "convert", "convert", "match", "copy", "diff"}
+
+// StageImages is the stage reported through Options.Progress for each image
+// comparison within the matching step
+const StageImages = "images"
+
+// Comparison directions for Options.Direction
+const (
+	DirectionBoth    = "both"    // report removals, modifications and additions
+	DirectionForward = "forward" // report only what File1 has that File2 dropped or changed
+)
+
+// Directions lists the accepted Options.Direction values
+var Directions = []string{DirectionBoth, DirectionForward}
+
+// Options configures a comparison run
+type Options struct {
+	File1         string    // older .docx
+	File2         string    // newer .docx
+	Label1        string    // name for File1 in output paths and the diff (default: its base name)
+	Label2        string    // name for File2 in output paths and the diff (default: its base name)
+	OutputDir     string    // directory for diff.md and image artifacts (default: DefaultOutputDir)
+	MarkdownDir   string    // directory for the per-document <docx>.md files (default: OutputDir)
+	KeepRendered  bool      // also save both markdown files to OutputDir with links to the image copies in imgs/
+	Gitignore     bool      // write OutputDir/.gitignore ignoring everything but diff.md
+	NoCleanup     bool      // keep the extracted documents in their temp directories (Result.TempDirs) for debugging
+	ConvertPNG    bool      // convert vector images (wmf/emf/svg) to PNG before comparison
+	StripMetadata bool      // auto-orient and strip metadata from raster images before comparison
+	DiffFormat    string    // diff image format: png (default), webp or avif
+	DiffImageName string    // diff image name template with {name1} and {name2} (default: image.DefaultDiffName)
+	TempSuffix    string    // suffix of diff images before they are renamed (default: image.DefaultTempSuffix)
+	Converter     string    // markdown converter: markitdown, pandoc, or "" / "auto" to pick an installed one
+	Encoding      string    // encoding of the markitdown output, e.g. "shift_jis" (default: UTF-8); pandoc output is always UTF-8
+	Retries       int       // retries for transient markitdown/magick failures
+	Since         time.Time // if set, skip images whose zip mtime is before this time
+	MaxDimension  int       // if > 0, downscale image pairs larger than this many pixels before comparison
+	NoMagick      bool      // compare PNG, JPEG and GIF images in Go without ImageMagick and skip other formats; ConvertPNG is ignored
+	MaxImages     int       // if > 0, fail with ErrTooManyImages when a document has more images
+	Metrics       []string  // metrics recorded on changed image pairs, e.g. {"psnr", "ssim"}
+	SortBy        string    // order of changed images: "psnr" (most different first), "name", or "" for matching order
+	Direction     string    // DirectionBoth (default, also "") or DirectionForward
+	DiffAlgorithm string    // text diff algorithm: myers (default, also ""), patience or histogram
+	TextFormat    string    // layout of diff.md: unified (default, also ""), side-by-side, or patch (unified plus diff.patch)
+
+	// LosslessThreshold and LossyThreshold override the PSNR below which
+	// lossless (PNG, BMP, ...) and lossy (JPEG, WebP) image pairs count as
+	// different. Zero keeps the built-in defaults.
+	LosslessThreshold float64
+	LossyThreshold    float64
+
+	NormalizeUnicode bool   // NFC-normalize markdown before diffing
+	Sanitize         bool   // make control characters other than tab and newline visible before diffing
+	IgnoreCase       bool   // treat lines that differ only in letter case as unchanged in the text diff
+	IgnoreWhitespace bool   // treat lines that differ only in white space as unchanged in the text diff
+	SharedImageNames bool   // name images matched in both documents shared/imageN.ext in the diff instead of by File1's name
+	Section          string // if set, diff only the markdown under the heading with this title
+	TableDiff        bool   // append cell-level table changes to diff.md
+	DetectMoves      bool   // append blocks moved without changes to diff.md
+	StyleDiff        bool   // append changes to paragraph styles and run formatting to diff.md
+	IncludeHeaders   bool   // diff the text of the page headers as a "## Headers" section
+	IncludeFooters   bool   // diff the text of the page footers as a "## Footers" section
+	IncludeFootnotes bool   // diff the footnotes as a "## Footnotes" section
+	FrontMatter      bool   // prepend a YAML front-matter block to diff.md
+	FenceLang        string // info string of the diff.md code fence: "" for "diff", "none" for a bare fence
+
+	// PreFilters are "<regex>=<replacement>" rules applied in order to both
+	// normalized markdown texts before diffing, e.g. to mask generated dates
+	// or document IDs (see markdown.ParseFilter)
+	PreFilters []string
+
+	IncludeUnchangedImages bool     // also copy originals of matched images to imgs/original/
+	OnlyChangedImages      bool     // copy originals of changed pairs only, not of added or removed images
+	Flatten                bool     // copy originals to imgs/<label>__<name> instead of imgs/original/<label>/<name>
+	Manifest               bool     // write imgs/manifest.json with the SHA-256 of each diff image
+	VerifyManifest         bool     // compare the diff images against the existing imgs/manifest.json
+	KeepIdenticalDiffs     bool     // keep heatmaps of identical pairs as imgs/<name1>-<name2>.identical.<ext>
+	Fuzz                   float64  // magick compare -fuzz percentage: colors this close count as equal (0: exact)
+	CrossFormat            bool     // also match leftover raster images across extensions, e.g. PNG re-exported as JPEG
+	IgnoreImageHashes      []string // SHA-256 digests of images to leave out of the comparison
+	IgnoreAlt              []string // skip images whose alt text contains one of these (case-insensitive)
+	ChangedSectionsOnly    bool     // skip images not referenced in a heading section whose text changed
+	ImageRange             string   // "<start>:<end>": compare only media at these 1-based positions of each document in natural (numeric) name order
+	MediaPrefixes          []string // extra archive prefixes treated as media besides word/media/
+
+	// TextWeight and ImageWeight weight the text and image components of
+	// the similarity score. Both zero means equal weights.
+	TextWeight  float64
+	ImageWeight float64
+
+	// Progress, if set, is called at each of the Steps pipeline steps with
+	// the step description and its 1-based number, and during image matching
+	// with StageImages and the number of comparisons done and planned.
+	Progress func(stage string, current, total int)
+
+	// Debugf, if set, receives debug messages such as command retries.
+	Debugf func(format string, args ...any)
+
+	// ToolLimiter, if set, bounds the converter and magick compare commands
+	// running at once across all Runs that share it.
+	ToolLimiter ToolLimiter
+}
+
+// ToolLimiter bounds the number of external commands running at once
+type ToolLimiter = retry.Limiter
+
+// NewToolLimiter returns a ToolLimiter that allows n concurrent commands
+func NewToolLimiter(n int) ToolLimiter {
+	return retry.NewLimiter(n)
+}
+
+// Result holds the outcome of a comparison run
+type Result struct {
+	File1       string             // path of the older .docx
+	File2       string             // path of the newer .docx
+	Doc1Base    string             // label of File1: Label1 or its disambiguated base name
+	Doc2Base    string             // label of File2: Label2 or its disambiguated base name
+	OutputDir   string             // resolved output directory
+	DiffPath    string             // path to the generated diff.md
+	PatchPath   string             // path to the generated diff.patch, for TextFormat patch
+	Markdown1   string             // path of the saved markdown of File1
+	Markdown2   string             // path of the saved markdown of File2
+	Rendered1   string             // path of the markdown of File1 linking to imgs/, with KeepRendered
+	Rendered2   string             // path of the markdown of File2 linking to imgs/, with KeepRendered
+	Diff        string             // unified diff of the normalized markdown, without pure additions for DirectionForward
+	DiffStat    DiffStat           // insertions and deletions, including unreported additions
+	Words       WordStat           // word counts of the normalized markdown
+	Normalized1 string             // normalized markdown of File1
+	Normalized2 string             // normalized markdown of File2
+	TextChanged bool               // whether the normalized markdown differs
+	TableDiff   string             // "## Table Changes" section, if TableDiff is enabled
+	Moves       []markdown.Move    // blocks moved without changes, if DetectMoves is enabled
+	StyleDiff   string             // unified diff of the structure markdown, if StyleDiff is enabled
+	MatchResult *image.MatchResult // image comparison result
+	AddedImages []image.ImageInfo  // images only in File2, held out of MatchResult by DirectionForward
+	Similarity  Similarity         // how alike the two documents are
+	Drift       []image.Drift      // diff images that differ from the manifest, if VerifyManifest is enabled
+	Warnings    []string           // non-fatal problems, e.g. converter warnings
+	Macros      Macros             // which documents contain VBA macros
+	Timings     []StageTiming      // wall-clock time per pipeline stage, in order
+	TempDirs    []string           // extracted documents left behind by NoCleanup, File1's first
+	Originals   []string           // original images copied below imgs/, sorted
+}
+
+// Incomplete lists what the comparison left out: every skipped or broken
+// image with the reason, and every warning. It is empty for a complete
+// comparison.
+func (r *Result) Incomplete() []string {
+	var problems []string
+	for _, img := range r.MatchResult.Broken {
+		problems = append(problems, fmt.Sprintf("image %s is %s", img.Name, img.Skip))
+	}
+	for _, img := range r.MatchResult.Skipped {
+		problems = append(problems, fmt.Sprintf("image %s skipped: %s", img.Name, img.Skip))
+	}
+	return append(problems, r.Warnings...)
+}
+
+// Macros records which documents contain VBA macros (word/vbaProject.bin)
+type Macros struct {
+	File1 bool `json:"file1"`
+	File2 bool `json:"file2"`
+}
+
+// MediaBytes totals the file sizes of the compared images
+type MediaBytes struct {
+	Changed int64 `json:"changedBytes"` // both sides of changed pairs, plus added and removed images
+	Total   int64 `json:"totalBytes"`   // Changed plus both sides of matched pairs
+}
+
+// MediaBytes reports how many bytes of image data changed between the
+// documents. Skipped images are not counted.
+func (r *Result) MediaBytes() MediaBytes {
+	var b MediaBytes
+	m := r.MatchResult
+	for _, pair := range m.Different {
+		b.Changed += pair.Image1.Size + pair.Image2.Size
+	}
+	for _, images := range [][]image.ImageInfo{m.OnlyIn1, m.OnlyIn2, r.AddedImages} {
+		for _, img := range images {
+			b.Changed += img.Size
+		}
+	}
+	b.Total = b.Changed
+	for _, pair := range m.Matched {
+		b.Total += pair.Image1.Size + pair.Image2.Size
+	}
+	return b
+}
+
+// ImagesChanged reports whether any image was changed, added or removed.
+func (r *Result) ImagesChanged() bool {
+	return len(r.MatchResult.Different)+len(r.MatchResult.OnlyIn1)+len(r.MatchResult.OnlyIn2) > 0
+}
+
+func (o Options) matchOptions(ctx context.Context) image.MatchOptions {
+	return image.MatchOptions{
+		ConvertPNG:    o.ConvertPNG && !o.NoMagick,
+		StripMetadata: o.StripMetadata,
+		DiffFormat:    o.DiffFormat,
+		DiffName:      o.DiffImageName,
+		TempSuffix:    o.TempSuffix,
+		Retry:         o.retryPolicy(ctx),
+		Since:         o.Since,
+		MaxDimension:  o.MaxDimension,
+		NoMagick:      o.NoMagick,
+		IgnoreHashes:  o.IgnoreImageHashes,
+		Metrics:       o.Metrics,
+		Manifest:      o.Manifest,
+
+		KeepIdenticalDiffs: o.KeepIdenticalDiffs,
+		Fuzz:               o.Fuzz,
+		CrossFormat:        o.CrossFormat,
+
+		LosslessThreshold: o.LosslessThreshold,
+		LossyThreshold:    o.LossyThreshold,
+	}
+}
+
+func (o Options) extractOptions() docx.Options {
+	return docx.Options{MediaPrefixes: o.MediaPrefixes}
+}
+
+func (o Options) diffOptions() diff.Options {
+	return diff.Options{Algorithm: o.DiffAlgorithm, IgnoreCase: o.IgnoreCase, IgnoreWhitespace: o.IgnoreWhitespace}
+}
+
+func (o Options) fenceLang() string {
+	switch o.FenceLang {
+	case "":
+		return "diff"
+	case "none":
+		return ""
+	}
+	return o.FenceLang
+}
+
+func (o Options) progress(stage string, current, total int) {
+	if o.Progress != nil {
+		o.Progress(stage, current, total)
+	}
+}
+
+func (o Options) step(n int, desc string) {
+	o.progress(desc, n, Steps)
+}
+
+func (o Options) debugf(format string, args ...any) {
+	if o.Debugf != nil {
+		o.Debugf(format, args...)
+	}
+}
+
+// retryPolicy is the policy for converter and magick commands, which are
+// killed when ctx is done
+func (o Options) retryPolicy(ctx context.Context) retry.Policy {
+	return retry.Policy{Retries: o.Retries, Logf: o.Debugf, Limiter: o.ToolLimiter, Context: ctx}
+}
+
+func (o Options) markdownOptions(ctx context.Context, outputPath string) markdown.Options {
+	return markdown.Options{Converter: o.Converter, Retry: o.retryPolicy(ctx), OutputPath: outputPath, Encoding: o.Encoding}
+}
+
+// excludedByAlt returns the paths of the images whose alt text contains
+// one of IgnoreAlt, with the reason for skipping them
+func (o Options) excludedByAlt(alts ...map[string]string) map[string]string {
+	if len(o.IgnoreAlt) == 0 {
+		return nil
+	}
+	exclude := make(map[string]string)
+	for _, m := range alts {
+		for path, alt := range m {
+			alt = strings.ToLower(alt)
+			for _, sub := range o.IgnoreAlt {
+				if strings.Contains(alt, strings.ToLower(sub)) {
+					exclude[path] = fmt.Sprintf("alt text contains %q", sub)
+					break
+				}
+			}
+		}
+	}
+	return exclude
+}
+
+// checkImageCount enforces MaxImages on an extracted document
+func (o Options) checkImageCount(file string, extract *docx.ExtractResult) error {
+	if o.MaxImages > 0 && len(extract.Images) > o.MaxImages {
+		return fmt.Errorf("%s has %d images, more than the limit of %d: %w",
+			file, len(extract.Images), o.MaxImages, ErrTooManyImages)
+	}
+	return nil
+}
+
+// documentParts returns the parts besides the body to diff as text
+func (o Options) documentParts() []string {
+	var parts []string
+	if o.IncludeHeaders {
+		parts = append(parts, docx.PartHeaders)
+	}
+	if o.IncludeFooters {
+		parts = append(parts, docx.PartFooters)
+	}
+	if o.IncludeFootnotes {
+		parts = append(parts, docx.PartFootnotes)
+	}
+	return parts
+}
+
+// markdownPaths returns where the per-document markdown files are saved
+func (o Options) markdownPaths(outputDir, doc1Base, doc2Base string) (string, string) {
+	dir := o.MarkdownDir
+	if dir == "" {
+		dir = outputDir
+	}
+	return filepath.Join(dir, doc1Base+".md"), filepath.Join(dir, doc2Base+".md")
+}
+
+// docLabels returns the names used for the two documents in output paths
+// and the diff: Label1 and Label2 when set, otherwise the docx base names.
+// Base names that collide are prefixed with their parent directory names,
+// or suffixed with -1 and -2 when those are the same too.
+func (o Options) docLabels() (string, string, error) {
+	label1, label2 := o.Label1, o.Label2
+	if label1 == "" {
+		label1 = DocxBaseName(o.File1)
+	}
+	if label2 == "" {
+		label2 = DocxBaseName(o.File2)
+	}
+	for _, label := range []string{label1, label2} {
+		if label == "." || label == ".." || strings.ContainsAny(label, `/\`) {
+			return "", "", fmt.Errorf("invalid document label %q", label)
+		}
+	}
+	if label1 != label2 {
+		return label1, label2, nil
+	}
+	if o.Label1 != "" && o.Label2 != "" {
+		return "", "", fmt.Errorf("document labels must differ, both are %q", label1)
+	}
+
+	parent1 := filepath.Base(filepath.Dir(o.File1))
+	parent2 := filepath.Base(filepath.Dir(o.File2))
+	if o.Label1 == "" && o.Label2 == "" && parent1 != parent2 {
+		return parent1 + "-" + label1, parent2 + "-" + label2, nil
+	}
+	return label1 + "-1", label2 + "-2", nil
+}
+
+// checkOutputDir refuses an output directory that contains one of the
+// inputs, such as "." for documents in the working directory: artifacts
+// would be written next to the inputs and `ddx clean` would delete them.
+func checkOutputDir(outputDir string, inputs ...string) error {
+	absOut, err := resolvedAbs(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+	for _, input := range inputs {
+		absDir, err := resolvedAbs(filepath.Dir(input))
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(absOut, absDir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("output directory %s contains the input %s; choose another output directory", outputDir, input)
+		}
+	}
+	return nil
+}
+
+// resolvedAbs returns the absolute path of path with symlinks resolved as
+// far as it exists
+func resolvedAbs(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved, nil
+	}
+	return abs, nil
+}
+
+// DocxBaseName returns the file name of path without its extension.
+func DocxBaseName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// Run extracts both documents, converts them to markdown, matches their
+// images and writes diff artifacts to the output directory.
+func Run(opts Options) (*Result, error) {
+	return RunContext(context.Background(), opts)
+}
+
+// RunContext is Run with a context: when ctx is done, running converter and
+// magick compare commands are killed and the comparison fails with an error
+// wrapping ctx.Err().
+func RunContext(ctx context.Context, opts Options) (*Result, error) {
+	result, err := run(ctx, opts)
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("comparison aborted: %w", ctx.Err())
+	}
+	return result, err
+}
+
+func run(ctx context.Context, opts Options) (*Result, error) {
+	var timer stageTimer
+	step := func(n int, desc string) {
+		timer.begin(stageNames[n-1])
+		opts.step(n, desc)
+	}
+	file1, file2 := opts.File1, opts.File2
+	doc1Base, doc2Base, err := opts.docLabels()
+	if err != nil {
+		return nil, err
+	}
+	preFilters, err := markdown.ParseFilters(opts.PreFilters)
+	if err != nil {
+		return nil, err
+	}
+	var imageRange image.ImageRange
+	if opts.ImageRange != "" {
+		if imageRange, err = image.ParseImageRange(opts.ImageRange); err != nil {
+			return nil, err
+		}
+	}
+
+	outputDir := opts.OutputDir
+	if outputDir == "" {
+		outputDir = DefaultOutputDir
+	}
+
+	if err := checkOutputDir(outputDir, file1, file2); err != nil {
+		return nil, err
+	}
+
+	if opts.Converter == "" || opts.Converter == "auto" {
+		converter, err := markdown.SelectConverter(opts.Converter)
+		if err != nil {
+			return nil, err
+		}
+		opts.Converter = converter
+		opts.debugf("auto-selected converter: %s", converter)
+	}
+
+	// 1. Extract docx files to temp directories
+	var tempDirs []string
+	step(1, "Extracting "+filepath.Base(file1)+"...")
+	extract1, err := docx.Extract(file1, opts.extractOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract %s: %w", file1, err)
+	}
+	if opts.NoCleanup {
+		tempDirs = append(tempDirs, extract1.TempDir)
+	} else {
+		defer extract1.CleanupFn()
+	}
+	if err := opts.checkImageCount(file1, extract1); err != nil {
+		return nil, err
+	}
+
+	step(2, "Extracting "+filepath.Base(file2)+"...")
+	extract2, err := docx.Extract(file2, opts.extractOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract %s: %w", file2, err)
+	}
+	if opts.NoCleanup {
+		tempDirs = append(tempDirs, extract2.TempDir)
+	} else {
+		defer extract2.CleanupFn()
+	}
+	if err := opts.checkImageCount(file2, extract2); err != nil {
+		return nil, err
+	}
+
+	// 2. Create output directory structure
+	diffImgsDir := filepath.Join(outputDir, "imgs")
+
+	// The original/ directories are created on demand by CopyFile so that a
+	// document without any copied image does not leave an empty directory.
+	if err := os.MkdirAll(diffImgsDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", diffImgsDir, err)
+	}
+	if opts.Gitignore {
+		if err := writeGitignore(outputDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// 3. Convert to markdown and save alongside docx
+	step(3, "Converting "+filepath.Base(file1)+" to markdown...")
+	mdPath1, mdPath2 := opts.markdownPaths(outputDir, doc1Base, doc2Base)
+	md1, err := markdown.ProcessMarkdown(file1, extract1.Images, extract1.TempDir, opts.markdownOptions(ctx, mdPath1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to process %s: %w", file1, err)
+	}
+
+	step(4, "Converting "+filepath.Base(file2)+" to markdown...")
+	md2, err := markdown.ProcessMarkdown(file2, extract2.Images, extract2.TempDir, opts.markdownOptions(ctx, mdPath2))
+	if err != nil {
+		return nil, fmt.Errorf("failed to process %s: %w", file2, err)
+	}
+
+	var warnings []string
+	for _, md := range []struct {
+		file   string
+		result *markdown.ProcessResult
+	}{{file1, md1}, {file2, md2}} {
+		for _, w := range md.result.ConversionWarnings {
+			warnings = append(warnings, filepath.Base(md.file)+": "+w)
+		}
+	}
+
+	// The manifest to verify against must be read before matching replaces it
+	manifestPath := filepath.Join(diffImgsDir, image.ManifestName)
+	var previous *image.Manifest
+	if opts.VerifyManifest {
+		previous, err = image.ReadManifest(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 4. Image matching
+	step(5, "Matching images...")
+	matchOpts := opts.matchOptions(ctx)
+	matchOpts.Range = imageRange
+	matchOpts.Exclude = opts.excludedByAlt(md1.AltTexts, md2.AltTexts)
+	if opts.ChangedSectionsOnly {
+		outside, err := outsideChangedSections(md1.Content, md2.Content, extract1.Images, extract2.Images, opts)
+		if err != nil {
+			return nil, err
+		}
+		if matchOpts.Exclude == nil {
+			matchOpts.Exclude = outside
+		} else {
+			for path, reason := range outside {
+				if _, ok := matchOpts.Exclude[path]; !ok {
+					matchOpts.Exclude[path] = reason
+				}
+			}
+		}
+	}
+	matchOpts.Progress = func(done, total int) {
+		opts.progress(StageImages, done, total)
+	}
+	matchResult, err := image.MatchImageSets(extract1.Images, extract2.Images, diffImgsDir, matchOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to match images: %w", err)
+	}
+	matchResult.SortDifferent(opts.SortBy)
+	var drift []image.Drift
+	if previous != nil {
+		current, err := image.BuildManifest(matchResult)
+		if err != nil {
+			return nil, err
+		}
+		drift = previous.Compare(current)
+	}
+	var addedImages []image.ImageInfo
+	if opts.Direction == DirectionForward {
+		addedImages, matchResult.OnlyIn2 = matchResult.OnlyIn2, nil
+	}
+
+	// 5. Copy original images for changed pairs (and matched ones if requested)
+	step(6, "Copying original images...")
+	dests := newOriginals(diffImgsDir, opts.Flatten)
+	if err := copyOriginalImages(matchResult, dests, doc1Base, doc2Base, opts); err != nil {
+		return nil, fmt.Errorf("failed to copy original images: %w", err)
+	}
+	var rendered1, rendered2 string
+	if opts.KeepRendered {
+		images1, images2 := renderedImages(matchResult, addedImages)
+		if rendered1, err = writeRendered(md1.Content, outputDir, doc1Base, images1, dests); err != nil {
+			return nil, err
+		}
+		if rendered2, err = writeRendered(md2.Content, outputDir, doc2Base, images2, dests); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 6. Generate diff.md with normalized image paths
+	step(7, "Generating diff.md...")
+	map1, map2 := markdown.BuildPathMapping(matchResult, doc1Base, doc2Base, opts.SharedImageNames)
+	norm1 := markdown.NormalizeForDiff(md1.Content, map1)
+	norm2 := markdown.NormalizeForDiff(md2.Content, map2)
+	for _, doc := range []struct {
+		file string
+		dir  string
+		norm *string
+	}{{file1, extract1.TempDir, &norm1}, {file2, extract2.TempDir, &norm2}} {
+		// Chart data is diffed as text: the fallback image may not show the change
+		charts, chartWarnings, err := docx.Charts(doc.dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read charts of %s: %w", filepath.Base(doc.file), err)
+		}
+		*doc.norm += charts
+		for _, w := range chartWarnings {
+			warnings = append(warnings, filepath.Base(doc.file)+": "+w)
+		}
+		sheets, sheetWarnings, err := docx.Spreadsheets(doc.dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedded spreadsheets of %s: %w", filepath.Base(doc.file), err)
+		}
+		*doc.norm += sheets
+		parts, err := docx.Parts(doc.dir, opts.documentParts())
+		if err != nil {
+			return nil, err
+		}
+		*doc.norm += parts
+		for _, w := range sheetWarnings {
+			warnings = append(warnings, filepath.Base(doc.file)+": "+w)
+		}
+	}
+	// A byte order mark or blank lines at either end would show as a change
+	norm1 = markdown.TrimEdges(norm1)
+	norm2 = markdown.TrimEdges(norm2)
+	if opts.NormalizeUnicode {
+		norm1 = markdown.NormalizeUnicode(norm1)
+		norm2 = markdown.NormalizeUnicode(norm2)
+	}
+	if opts.Sanitize {
+		norm1 = markdown.SanitizeControl(norm1)
+		norm2 = markdown.SanitizeControl(norm2)
+	}
+	norm1 = markdown.ApplyFilters(norm1, preFilters)
+	norm2 = markdown.ApplyFilters(norm2, preFilters)
+	if opts.Section != "" {
+		section1, ok1 := markdown.Section(norm1, opts.Section)
+		section2, ok2 := markdown.Section(norm2, opts.Section)
+		if !ok1 && !ok2 {
+			return nil, fmt.Errorf("section %q not found in %s or %s", opts.Section, file1, file2)
+		}
+		if !ok1 {
+			warnings = append(warnings, fmt.Sprintf("section %q not found in %s; reporting it as added", opts.Section, filepath.Base(file1)))
+		}
+		if !ok2 {
+			warnings = append(warnings, fmt.Sprintf("section %q not found in %s; reporting it as removed", opts.Section, filepath.Base(file2)))
+		}
+		norm1, norm2 = section1, section2
+	}
+
+	// Write normalized markdown to temp files for diff
+	tmpDir, err := os.MkdirTemp("", "ddx-normdiff-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	normPath1 := filepath.Join(tmpDir, doc1Base+".md")
+	normPath2 := filepath.Join(tmpDir, doc2Base+".md")
+
+	if err := os.WriteFile(normPath1, []byte(norm1), 0644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(normPath2, []byte(norm2), 0644); err != nil {
+		return nil, err
+	}
+
+	diffPath := filepath.Join(outputDir, "diff.md")
+	diffText, err := diff.UnifiedWith(normPath1, normPath2, opts.diffOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate diff.md: %w", err)
+	}
+	reported := diffText
+	if opts.Direction == DirectionForward {
+		reported = diff.DropAdditions(diffText)
+	}
+	if opts.TextFormat == diff.FormatSideBySide {
+		err = diff.WriteSideBySide(reported, diffPath, doc1Base, doc2Base)
+	} else {
+		err = diff.WriteDiffFile(reported, diffPath, opts.fenceLang())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate diff.md: %w", err)
+	}
+	patchPath := ""
+	if opts.TextFormat == diff.FormatPatch {
+		patchPath = filepath.Join(outputDir, "diff.patch")
+		if err := diff.WritePatch(reported, patchPath, doc1Base, doc2Base); err != nil {
+			return nil, fmt.Errorf("failed to generate diff.patch: %w", err)
+		}
+	}
+
+	tableDiff := ""
+	if opts.TableDiff {
+		tableDiff = markdown.DiffTables(norm1, norm2)
+		if err := appendSection(diffPath, tableDiff); err != nil {
+			return nil, fmt.Errorf("failed to write table changes: %w", err)
+		}
+	}
+
+	var moves []markdown.Move
+	if opts.DetectMoves {
+		moves = markdown.DetectMoves(diffText)
+		if err := appendSection(diffPath, markdown.FormatMoves(moves)); err != nil {
+			return nil, fmt.Errorf("failed to write moved sections: %w", err)
+		}
+	}
+
+	styleDiff := ""
+	if opts.StyleDiff {
+		styleDiff, err = diffStructure(extract1.TempDir, extract2.TempDir, tmpDir, opts.diffOptions())
+		if err != nil {
+			return nil, err
+		}
+		if styleDiff != "" {
+			section := "## Style Changes\n\n```" + opts.fenceLang() + "\n" + styleDiff + "```\n"
+			if err := appendSection(diffPath, section); err != nil {
+				return nil, fmt.Errorf("failed to write style changes: %w", err)
+			}
+		}
+	}
+
+	result := &Result{
+		File1:       file1,
+		File2:       file2,
+		Doc1Base:    doc1Base,
+		Doc2Base:    doc2Base,
+		OutputDir:   outputDir,
+		DiffPath:    diffPath,
+		PatchPath:   patchPath,
+		Markdown1:   md1.OutputPath,
+		Markdown2:   md2.OutputPath,
+		Rendered1:   rendered1,
+		Rendered2:   rendered2,
+		Diff:        reported,
+		DiffStat:    diffStat(diffText),
+		Words:       wordStat(norm1, norm2),
+		Normalized1: norm1,
+		Normalized2: norm2,
+		TextChanged: reported != "",
+		AddedImages: addedImages,
+		Drift:       drift,
+		TableDiff:   tableDiff,
+		Moves:       moves,
+		StyleDiff:   styleDiff,
+		MatchResult: matchResult,
+		Warnings:    warnings,
+		TempDirs:    tempDirs,
+		Macros:      Macros{File1: extract1.HasMacros, File2: extract2.HasMacros},
+		Originals:   dests.all(),
+	}
+	result.Similarity = computeSimilarity(result, opts.TextWeight, opts.ImageWeight)
+	timer.end()
+	result.Timings = timer.timings
+
+	if opts.FrontMatter {
+		if err := prependFile(diffPath, frontMatter(result, time.Now())); err != nil {
+			return nil, fmt.Errorf("failed to write front matter: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// diffStructure diffs the structure markdown (paragraph styles and run
+// formatting) of two extracted documents, using tmpDir for the inputs of diff
+func diffStructure(extractDir1, extractDir2, tmpDir string, opts diff.Options) (string, error) {
+	var paths [2]string
+	for i, dir := range []string{extractDir1, extractDir2} {
+		structure, err := docx.Structure(dir)
+		if err != nil {
+			return "", fmt.Errorf("failed to read document styles: %w", err)
+		}
+		paths[i] = filepath.Join(tmpDir, fmt.Sprintf("structure%d.md", i+1))
+		if err := os.WriteFile(paths[i], []byte(structure), 0644); err != nil {
+			return "", err
+		}
+	}
+	return diff.UnifiedWith(paths[0], paths[1], opts)
+}
+
+// appendSection appends a markdown section to the file at path, separated by
+// a blank line. Empty sections are ignored.
+func appendSection(path, section string) error {
+	if section == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString("\n" + section)
+	return err
+}
+
+// copyJob copies one original image into the output tree
+type copyJob struct {
+	name string
+	src  string
+	dst  string
+}
+
+// maxCopyWorkers bounds the number of concurrent original image copies
+const maxCopyWorkers = 8
+
+// originals assigns where the originals of media files are copied:
+// imgs/original/<base>/<name>, or imgs/<base>__<name> with subfolders joined
+// by "_" when flattening. Names that flatten to the same file, such as
+// sub/image1.png and sub_image1.png, get distinct paths.
+type originals struct {
+	imgsDir string
+	flatten bool
+	paths   map[string]string // base + "\x00" + name -> destination
+	used    map[string]bool
+}
+
+func newOriginals(imgsDir string, flatten bool) *originals {
+	return &originals{
+		imgsDir: imgsDir,
+		flatten: flatten,
+		paths:   make(map[string]string),
+		used:    make(map[string]bool),
+	}
+}
+
+// path returns the destination of the media file name of the document
+// labeled base, the same one on every call
+func (o *originals) path(base, name string) string {
+	key := base + "\x00" + name
+	if p, ok := o.paths[key]; ok {
+		return p
+	}
+	var p string
+	if o.flatten {
+		p = image.UniquePath(o.imgsDir, base+"__"+strings.ReplaceAll(name, "/", "_"), o.used)
+	} else {
+		p = image.UniquePath(filepath.Join(o.imgsDir, "original", base), name, o.used)
+	}
+	o.paths[key] = p
+	return p
+}
+
+// all returns every destination assigned so far, sorted
+func (o *originals) all() []string {
+	paths := make([]string, 0, len(o.paths))
+	for _, p := range o.paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func copyOriginalImages(matchResult *image.MatchResult, dests *originals, doc1Base, doc2Base string, opts Options) error {
+	var jobs []copyJob
+	seen := make(map[string]bool)
+	add := func(img image.ImageInfo, base string) {
+		dst := dests.path(base, img.Name)
+		if seen[dst] {
+			return
+		}
+		seen[dst] = true
+		jobs = append(jobs, copyJob{name: img.Name, src: img.Path, dst: dst})
+	}
+
+	// Copy originals for matched pairs when a full inventory is requested
+	if opts.IncludeUnchangedImages {
+		for _, pair := range matchResult.Matched {
+			add(pair.Image1, doc1Base)
+			add(pair.Image2, doc2Base)
+		}
+	}
+
+	// Copy originals for different pairs
+	for _, pair := range matchResult.Different {
+		add(pair.Image1, doc1Base)
+		add(pair.Image2, doc2Base)
+	}
+
+	// Copy originals for only-in-one unless only comparable pairs are wanted
+	if !opts.OnlyChangedImages {
+		for _, img := range matchResult.OnlyIn1 {
+			add(img, doc1Base)
+		}
+		for _, img := range matchResult.OnlyIn2 {
+			add(img, doc2Base)
+		}
+	}
+
+	return runCopyJobs(jobs)
+}
+
+// runCopyJobs copies files with a bounded worker pool. Destinations that
+// already hold identical content are left untouched. The error reported is
+// that of the first failing job in order, regardless of completion order.
+func runCopyJobs(jobs []copyJob) error {
+	errs := make([]error, len(jobs))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(maxCopyWorkers, runtime.NumCPU(), len(jobs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				job := jobs[i]
+				if same, err := image.SameContent(job.src, job.dst); err == nil && same {
+					continue
+				}
+				if err := image.CopyFile(job.src, job.dst); err != nil {
+					errs[i] = fmt.Errorf("failed to copy %s: %w", job.name, err)
+				}
+			}
+		}()
+	}
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package ddx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shioshosho/diff-docx/internal/image"
+)
+
+// TestCopyOriginalImagesOneSided checks that matching against a document
+// without images copies the other side's originals and creates no empty
+// original/<doc> directory for the side without images
+func TestCopyOriginalImagesOneSided(t *testing.T) {
+	media := t.TempDir()
+	images := make(map[string]string)
+	for _, name := range []string{"image1.png", "image2.png", "image3.png"} {
+		path := filepath.Join(media, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		images[name] = path
+	}
+
+	tests := []struct {
+		name             string
+		images1, images2 map[string]string
+		present, absent  string
+	}{
+		{name: "all added", images1: map[string]string{}, images2: images, present: "revised", absent: "draft"},
+		{name: "all removed", images1: images, images2: map[string]string{}, present: "draft", absent: "revised"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imgsDir := filepath.Join(t.TempDir(), "imgs")
+			if err := os.MkdirAll(imgsDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			result, err := image.MatchImageSets(tt.images1, tt.images2, imgsDir, image.MatchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(result.OnlyIn1) + len(result.OnlyIn2); got != len(images) {
+				t.Fatalf("got %d one-sided images, want %d", got, len(images))
+			}
+			if len(result.Matched)+len(result.Different) != 0 {
+				t.Fatalf("got pairs %v %v, want none", result.Matched, result.Different)
+			}
+
+			if err := copyOriginalImages(result, newOriginals(imgsDir, false), "draft", "revised", Options{}); err != nil {
+				t.Fatal(err)
+			}
+			for name := range images {
+				if _, err := os.Stat(filepath.Join(imgsDir, "original", tt.present, name)); err != nil {
+					t.Errorf("original %s not copied: %v", name, err)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(imgsDir, "original", tt.absent)); !os.IsNotExist(err) {
+				t.Errorf("original/%s exists for the document without images (err %v)", tt.absent, err)
+			}
+		})
+	}
+}
+
+func TestOriginalsFlattenCollision(t *testing.T) {
+	imgsDir := filepath.Join("out", "imgs")
+	dests := newOriginals(imgsDir, true)
+	got := []string{
+		dests.path("draft", "sub/image1.png"),
+		dests.path("draft", "sub_image1.png"),
+		dests.path("draft", "sub/image1.png"),
+		dests.path("revised", "sub/image1.png"),
+	}
+	want := []string{
+		filepath.Join(imgsDir, "draft__sub_image1.png"),
+		filepath.Join(imgsDir, "draft__sub_image1-2.png"),
+		filepath.Join(imgsDir, "draft__sub_image1.png"),
+		filepath.Join(imgsDir, "revised__sub_image1.png"),
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("path #%d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
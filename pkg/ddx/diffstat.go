@@ -0,0 +1,60 @@
+package ddx
+
+import (
+	"fmt"
+
+	"github.com/shioshosho/diff-docx/internal/diff"
+	"github.com/shioshosho/diff-docx/internal/markdown"
+)
+
+// DiffStat counts the lines added and removed by the markdown diff
+type DiffStat struct {
+	Insertions int `json:"insertions"`
+	Deletions  int `json:"deletions"`
+}
+
+// String formats the stat like git diff --shortstat, e.g.
+// "40 insertions(+), 12 deletions(-)".
+func (s DiffStat) String() string {
+	return fmt.Sprintf("%d %s(+), %d %s(-)",
+		s.Insertions, plural(s.Insertions, "insertion", "insertions"),
+		s.Deletions, plural(s.Deletions, "deletion", "deletions"))
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+func diffStat(unified string) DiffStat {
+	added, removed := diff.CountChanges(unified)
+	return DiffStat{Insertions: added, Deletions: removed}
+}
+
+// WordStat compares the length of the two documents, counted on the
+// normalized markdown without image references and code blocks
+type WordStat struct {
+	Words1          int     `json:"words1"`
+	Words2          int     `json:"words2"`
+	ReadingMinutes1 float64 `json:"readingMinutes1"` // estimated at markdown.WordsPerMinute
+	ReadingMinutes2 float64 `json:"readingMinutes2"`
+}
+
+// Delta returns how many words the second document gained (negative if it
+// shrank)
+func (s WordStat) Delta() int {
+	return s.Words2 - s.Words1
+}
+
+func wordStat(content1, content2 string) WordStat {
+	words1 := markdown.WordCount(content1)
+	words2 := markdown.WordCount(content2)
+	return WordStat{
+		Words1:          words1,
+		Words2:          words2,
+		ReadingMinutes1: markdown.ReadingMinutes(words1),
+		ReadingMinutes2: markdown.ReadingMinutes(words2),
+	}
+}
@@ -0,0 +1,30 @@
+package ddx
+
+import (
+	"errors"
+
+	"github.com/shioshosho/diff-docx/internal/diff"
+	"github.com/shioshosho/diff-docx/internal/docx"
+	"github.com/shioshosho/diff-docx/internal/markdown"
+)
+
+// Errors returned by Run and CheckDependencies, wrapped with context. Use
+// errors.Is to test for them.
+var (
+	ErrNotDocx           = docx.ErrNotDocx               // an input is not a Word document
+	ErrDependencyMissing = diff.ErrDependencyMissing     // a required external tool is not installed
+	ErrConversionFailed  = markdown.ErrConversionFailed  // the markdown converter failed
+	ErrTooManyImages     = errors.New("too many images") // a document has more images than Options.MaxImages
+)
+
+// CheckDependencies reports ErrDependencyMissing if converter is not on
+// PATH. An empty converter or "auto" picks an installed one. ImageMagick is
+// optional: without it, set Options.NoMagick to compare PNG, JPEG and GIF
+// images in Go.
+func CheckDependencies(converter string) error {
+	name, err := markdown.SelectConverter(converter)
+	if err != nil {
+		return err
+	}
+	return diff.CheckDependencies(name, false, false)
+}
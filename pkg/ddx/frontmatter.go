@@ -0,0 +1,41 @@
+package ddx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frontMatter renders a YAML front-matter block describing the result.
+func frontMatter(r *Result, generated time.Time) string {
+	m := r.MatchResult
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", strconv.Quote("Diff: "+filepath.Base(r.File1)+" vs "+filepath.Base(r.File2)))
+	fmt.Fprintf(&b, "file1: %s\n", strconv.Quote(r.File1))
+	fmt.Fprintf(&b, "file2: %s\n", strconv.Quote(r.File2))
+	fmt.Fprintf(&b, "generated: %s\n", generated.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "text_changed: %t\n", r.TextChanged)
+	fmt.Fprintf(&b, "insertions: %d\n", r.DiffStat.Insertions)
+	fmt.Fprintf(&b, "deletions: %d\n", r.DiffStat.Deletions)
+	fmt.Fprintf(&b, "images_changed: %d\n", len(m.Different))
+	fmt.Fprintf(&b, "images_added: %d\n", len(m.OnlyIn2))
+	fmt.Fprintf(&b, "images_removed: %d\n", len(m.OnlyIn1))
+	fmt.Fprintf(&b, "images_unchanged: %d\n", len(m.Matched))
+	fmt.Fprintf(&b, "images_skipped: %d\n", len(m.Skipped))
+	fmt.Fprintf(&b, "similarity: %s\n", strconv.FormatFloat(percent(r.Similarity.Overall), 'f', -1, 64))
+	b.WriteString("---\n\n")
+	return b.String()
+}
+
+// prependFile inserts text at the start of the file at path.
+func prependFile(path, text string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(text), data...), 0644)
+}
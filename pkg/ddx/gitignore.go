@@ -0,0 +1,44 @@
+package ddx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitignoreLines ignore everything in the output directory but diff.md
+var gitignoreLines = []string{"*", "!diff.md"}
+
+// writeGitignore adds gitignoreLines to outputDir/.gitignore. Lines already
+// present are kept as they are, so repeated runs do not duplicate them.
+func writeGitignore(outputDir string) error {
+	path := filepath.Join(outputDir, ".gitignore")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	existing := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		existing[strings.TrimSpace(line)] = true
+	}
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	added := false
+	for _, line := range gitignoreLines {
+		if !existing[line] {
+			content += line + "\n"
+			added = true
+		}
+	}
+	if !added {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package ddx
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shioshosho/diff-docx/internal/diff"
+	"github.com/shioshosho/diff-docx/internal/image"
+	"github.com/shioshosho/diff-docx/internal/markdown"
+)
+
+// Severities of GitLab notes, following the GitLab code quality levels
+const (
+	SeverityInfo  = "info"
+	SeverityMinor = "minor"
+	SeverityMajor = "major"
+)
+
+// GitLabNote is one merge-request discussion note. Body is GitLab-flavored
+// markdown ready to be posted through the notes API.
+type GitLabNote struct {
+	Body     string `json:"body"`
+	Severity string `json:"severity"`
+	Kind     string `json:"kind"`              // "text" or "image"
+	Section  string `json:"section,omitempty"` // heading enclosing a text change
+	Image    string `json:"image,omitempty"`   // media name of an image change
+}
+
+// GitLabNotes builds one note per changed text hunk and per changed, added
+// or removed image.
+func (r *Result) GitLabNotes() []GitLabNote {
+	notes := []GitLabNote{}
+
+	for _, hunk := range diff.ParseHunks(r.Diff) {
+		section := markdown.EnclosingHeading(r.Normalized2, hunk.FirstChange())
+		title := "Text changed"
+		if section != "" {
+			title = fmt.Sprintf("Text changed in **%s**", section)
+		}
+		body := fmt.Sprintf("%s (lines %d-%d)\n\n```diff\n%s\n```",
+			title, hunk.NewStart, hunk.NewStart+max(hunk.NewLines-1, 0), strings.Join(hunk.Lines, "\n"))
+		notes = append(notes, GitLabNote{
+			Body:     body,
+			Severity: SeverityMinor,
+			Kind:     "text",
+			Section:  section,
+		})
+	}
+
+	m := r.MatchResult
+	for _, pair := range m.Different {
+		body := fmt.Sprintf("Image changed: `%s` → `%s`", pair.Image1.Name, pair.Image2.Name)
+		if pair.PSNR >= 0 {
+			body += fmt.Sprintf(" (PSNR %s)", image.FormatPSNR(pair.PSNR))
+		}
+		if pair.DiffPath != "" {
+			body += fmt.Sprintf("\n\nDiff image: `%s`", pair.DiffPath)
+		}
+		notes = append(notes, GitLabNote{Body: body, Severity: SeverityMajor, Kind: "image", Image: pair.Image2.Name})
+	}
+	for _, img := range m.OnlyIn1 {
+		notes = append(notes, GitLabNote{
+			Body:     fmt.Sprintf("Image removed: `%s`", img.Name),
+			Severity: SeverityMinor,
+			Kind:     "image",
+			Image:    img.Name,
+		})
+	}
+	for _, img := range m.OnlyIn2 {
+		notes = append(notes, GitLabNote{
+			Body:     fmt.Sprintf("Image added: `%s`", img.Name),
+			Severity: SeverityMinor,
+			Kind:     "image",
+			Image:    img.Name,
+		})
+	}
+
+	return notes
+}
@@ -0,0 +1,55 @@
+package ddx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/shioshosho/diff-docx/internal/image"
+	"github.com/shioshosho/diff-docx/internal/markdown"
+)
+
+// writeRendered saves the markdown of a document to outputDir as
+// <label>.md with every image linked to its original copied to dests, so
+// that the file still displays once the extracted documents are
+// removed. It returns the path written.
+func writeRendered(content, outputDir, label string, images []image.ImageInfo, dests *originals) (string, error) {
+	mapping := make(map[string]string, len(images))
+	jobs := make([]copyJob, 0, len(images))
+	for _, img := range images {
+		dst := dests.path(label, img.Name)
+		rel, err := filepath.Rel(outputDir, dst)
+		if err != nil {
+			return "", fmt.Errorf("failed to link %s: %w", img.Name, err)
+		}
+		mapping[img.Path] = filepath.ToSlash(rel)
+		jobs = append(jobs, copyJob{name: img.Name, src: img.Path, dst: dst})
+	}
+	if err := runCopyJobs(jobs); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(outputDir, label+".md")
+	rendered := markdown.TrimEdges(markdown.NormalizeForDiff(content, mapping))
+	if err := os.WriteFile(path, []byte(rendered), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// renderedImages returns the images of each document that its rendered
+// markdown links to: all but those skipped by filters
+func renderedImages(matchResult *image.MatchResult, addedImages []image.ImageInfo) (images1, images2 []image.ImageInfo) {
+	for _, pair := range matchResult.Matched {
+		images1 = append(images1, pair.Image1)
+		images2 = append(images2, pair.Image2)
+	}
+	for _, pair := range matchResult.Different {
+		images1 = append(images1, pair.Image1)
+		images2 = append(images2, pair.Image2)
+	}
+	images1 = append(images1, matchResult.OnlyIn1...)
+	images2 = append(images2, matchResult.OnlyIn2...)
+	images2 = append(images2, addedImages...)
+	return images1, images2
+}
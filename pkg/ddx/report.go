@@ -0,0 +1,161 @@
+package ddx
+
+import (
+	"math"
+
+	"github.com/shioshosho/diff-docx/internal/image"
+)
+
+// ReportSchemaVersion is the version of the Report JSON shape. It is raised
+// when a field is renamed or removed or changes meaning; adding a field
+// leaves it unchanged.
+const ReportSchemaVersion = 1
+
+// Report is the machine-readable summary of a comparison, used for --json.
+// Field names are part of the schema; optional fields are omitted when
+// empty, while lists are always present, possibly empty.
+type Report struct {
+	SchemaVersion int             `json:"schemaVersion"` // ReportSchemaVersion
+	File1         string          `json:"file1"`
+	File2         string          `json:"file2"`
+	DiffPath      string          `json:"diffPath"`
+	PatchPath     string          `json:"patchPath,omitempty"` // with TextFormat patch
+	TextChanged   bool            `json:"textChanged"`
+	DiffStat      DiffStat        `json:"diffStat"`
+	Words         WordStat        `json:"words"`
+	Images        ImagesReport    `json:"images"`
+	Media         MediaBytes      `json:"media"`
+	Similarity    SimilarityScore `json:"similarity"`
+	Drift         []image.Drift   `json:"manifestDrift,omitempty"` // with VerifyManifest
+	Macros        Macros          `json:"macros"`
+	Warnings      []string        `json:"warnings"`
+	Timings       []StageTiming   `json:"timings"`
+}
+
+// ImagesReport lists the image comparison outcome
+type ImagesReport struct {
+	Matched   []ImagePair `json:"matched"`
+	Different []ImagePair `json:"different"`
+	Removed   []string    `json:"removed"` // only in the first document
+	Added     []string    `json:"added"`   // only in the second document
+	Skipped   []string    `json:"skipped"`
+	Broken    []string    `json:"broken"` // empty or unreadable media, not compared
+}
+
+// ImagePair describes a pair of compared images
+type ImagePair struct {
+	Image1   string             `json:"image1"`
+	Image2   string             `json:"image2"`
+	PSNR     *float64           `json:"psnr"` // null when identical (infinite) or unknown
+	DiffPath string             `json:"diffPath,omitempty"`
+	Metrics  map[string]float64 `json:"metrics,omitempty"` // requested metrics such as "ssim"
+
+	// Dimensions1 and Dimensions2 are the pixel sizes of changed images,
+	// e.g. "1024x768", when they can be read
+	Dimensions1 string `json:"dimensions1,omitempty"`
+	Dimensions2 string `json:"dimensions2,omitempty"`
+}
+
+// SimilarityScore is the JSON form of Similarity, in percent
+type SimilarityScore struct {
+	Overall float64 `json:"overall"`
+	Text    float64 `json:"text"`
+	Images  float64 `json:"images"`
+}
+
+func psnrValue(psnr float64) *float64 {
+	if psnr < 0 || math.IsInf(psnr, 0) {
+		return nil
+	}
+	return &psnr
+}
+
+// metricValues drops infinite values, which JSON cannot represent.
+func metricValues(metrics map[string]float64) map[string]float64 {
+	if len(metrics) == 0 {
+		return nil
+	}
+	values := make(map[string]float64, len(metrics))
+	for name, value := range metrics {
+		if !math.IsInf(value, 0) && value >= 0 {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// dimensions renders a known image size, or "" for an unknown one
+func dimensions(d image.Dimensions) string {
+	if !d.Known() {
+		return ""
+	}
+	return d.String()
+}
+
+func imageNames(images []image.ImageInfo) []string {
+	names := make([]string, 0, len(images))
+	for _, img := range images {
+		names = append(names, img.Name)
+	}
+	return names
+}
+
+// Report builds the machine-readable summary of the result.
+func (r *Result) Report() Report {
+	m := r.MatchResult
+	images := ImagesReport{
+		Matched:   make([]ImagePair, 0, len(m.Matched)),
+		Different: make([]ImagePair, 0, len(m.Different)),
+		Removed:   imageNames(m.OnlyIn1),
+		Added:     imageNames(m.OnlyIn2),
+		Skipped:   imageNames(m.Skipped),
+		Broken:    imageNames(m.Broken),
+	}
+	for _, pair := range m.Matched {
+		images.Matched = append(images.Matched, ImagePair{
+			Image1:   pair.Image1.Name,
+			Image2:   pair.Image2.Name,
+			PSNR:     psnrValue(pair.PSNR),
+			DiffPath: pair.DiffPath,
+		})
+	}
+	for _, pair := range m.Different {
+		images.Different = append(images.Different, ImagePair{
+			Image1:   pair.Image1.Name,
+			Image2:   pair.Image2.Name,
+			PSNR:     psnrValue(pair.PSNR),
+			DiffPath: pair.DiffPath,
+			Metrics:  metricValues(pair.Metrics),
+
+			Dimensions1: dimensions(pair.Dims1),
+			Dimensions2: dimensions(pair.Dims2),
+		})
+	}
+
+	return Report{
+		SchemaVersion: ReportSchemaVersion,
+		File1:         r.File1,
+		File2:         r.File2,
+		DiffPath:      r.DiffPath,
+		PatchPath:     r.PatchPath,
+		TextChanged:   r.TextChanged,
+		DiffStat:      r.DiffStat,
+		Words:         r.Words,
+		Images:        images,
+		Media:         r.MediaBytes(),
+		Similarity: SimilarityScore{
+			Overall: percent(r.Similarity.Overall),
+			Text:    percent(r.Similarity.Text),
+			Images:  percent(r.Similarity.Images),
+		},
+		Drift:    r.Drift,
+		Macros:   r.Macros,
+		Warnings: append([]string{}, r.Warnings...),
+		Timings:  append([]StageTiming{}, r.Timings...),
+	}
+}
+
+// percent converts a 0..1 ratio to a percentage rounded to one decimal.
+func percent(ratio float64) float64 {
+	return math.Round(ratio*1000) / 10
+}
@@ -0,0 +1,77 @@
+package ddx
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shioshosho/diff-docx/internal/image"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// sampleResult is a Result with every field of the report set
+func sampleResult() *Result {
+	return &Result{
+		File1:       "before.docx",
+		File2:       "after.docx",
+		DiffPath:    "diff/diff.md",
+		PatchPath:   "diff/diff.patch",
+		TextChanged: true,
+		DiffStat:    DiffStat{Insertions: 3, Deletions: 1},
+		Words:       WordStat{Words1: 420, Words2: 436, ReadingMinutes1: 2.1, ReadingMinutes2: 2.2},
+		MatchResult: &image.MatchResult{
+			Matched: []image.MatchedPair{
+				{Image1: image.ImageInfo{Name: "image1.png", Size: 100}, Image2: image.ImageInfo{Name: "image1.png", Size: 100}, PSNR: math.Inf(1)},
+			},
+			Different: []image.DiffPair{{
+				Image1:   image.ImageInfo{Name: "image2.png", Size: 200},
+				Image2:   image.ImageInfo{Name: "image2.png", Size: 250},
+				PSNR:     0.42,
+				DiffPath: "diff/imgs/image2-image2.png",
+				Metrics:  map[string]float64{"psnr": 0.42, "ssim": 0.93},
+				Dims1:    image.Dimensions{Width: 1024, Height: 768},
+				Dims2:    image.Dimensions{Width: 1280, Height: 720},
+			}},
+			OnlyIn1: []image.ImageInfo{{Name: "image3.jpeg", Size: 300}},
+			OnlyIn2: []image.ImageInfo{{Name: "image4.gif", Size: 400}},
+			Skipped: []image.ImageInfo{{Name: "image5.emf", Skip: "unsupported format .emf"}},
+			Broken:  []image.ImageInfo{{Name: "image6.png", Skip: "empty"}},
+		},
+		Similarity: Similarity{Overall: 0.8125, Text: 0.875, Images: 0.75},
+		Drift:      []image.Drift{{Image: "image2-image2.png", Old: "aaaa", New: "bbbb"}},
+		Macros:     Macros{File2: true},
+		Warnings:   []string{"embedded oleObject1.xls is a binary .xls workbook; its contents are not compared"},
+		Timings: []StageTiming{
+			{Stage: "extract", Duration: 300 * time.Millisecond, Seconds: 0.3},
+			{Stage: "match", Duration: 1500 * time.Millisecond, Seconds: 1.5},
+		},
+	}
+}
+
+func TestReportGolden(t *testing.T) {
+	got, err := json.MarshalIndent(sampleResult().Report(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	golden := filepath.Join("testdata", "report.golden.json")
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("report JSON differs from %s (run go test -update if the change is intended; raise ReportSchemaVersion if a field was renamed or removed)\ngot:\n%s", golden, got)
+	}
+}
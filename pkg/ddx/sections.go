@@ -0,0 +1,111 @@
+package ddx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shioshosho/diff-docx/internal/diff"
+	"github.com/shioshosho/diff-docx/internal/markdown"
+)
+
+// notInChangedSection is the reason images outside changed sections are
+// skipped with ChangedSectionsOnly
+const notInChangedSection = "not in a changed section"
+
+// outsideChangedSections returns the paths of the images to leave out of
+// the comparison with ChangedSectionsOnly: those not referenced in a
+// heading section whose text changed. The sections are found with a
+// preliminary diff of the converted markdown in which each image link shows
+// the media name, so that an unchanged reference compares equal. An image
+// referenced in a changed section of either document is compared in both,
+// so that it is not reported as added or removed.
+func outsideChangedSections(content1, content2 string, images1, images2 map[string]string, opts Options) (map[string]string, error) {
+	text1 := markdown.NormalizeForDiff(content1, invert(images1))
+	text2 := markdown.NormalizeForDiff(content2, invert(images2))
+
+	tmpDir, err := os.MkdirTemp("", "ddx-sections-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path1 := filepath.Join(tmpDir, "doc1.md")
+	path2 := filepath.Join(tmpDir, "doc2.md")
+	if err := os.WriteFile(path1, []byte(text1), 0644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path2, []byte(text2), 0644); err != nil {
+		return nil, err
+	}
+	unified, err := diff.UnifiedWith(path1, path2, opts.diffOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to find changed sections: %w", err)
+	}
+
+	changed1, changed2 := changedLines(diff.ParseHunks(unified))
+	kept := make(map[string]bool)
+	for _, doc := range []struct {
+		content string
+		images  map[string]string
+		changed map[int]bool
+	}{{content1, images1, changed1}, {content2, images2, changed2}} {
+		inChanged := markdown.ChangedSections(doc.content, doc.changed)
+		for i, line := range strings.Split(doc.content, "\n") {
+			if !inChanged[i] {
+				continue
+			}
+			for name, path := range doc.images {
+				if strings.Contains(line, path) {
+					kept[name] = true
+				}
+			}
+		}
+	}
+
+	exclude := make(map[string]string)
+	for _, images := range []map[string]string{images1, images2} {
+		for name, path := range images {
+			if !kept[name] {
+				exclude[path] = notInChangedSection
+			}
+		}
+	}
+	return exclude, nil
+}
+
+// changedLines returns the 1-based numbers of the changed lines of each
+// file of a diff. Where lines were only removed, the line of the second
+// file at that place counts as changed too, and likewise for additions, so
+// that the corresponding section of each file is marked.
+func changedLines(hunks []diff.Hunk) (changed1, changed2 map[int]bool) {
+	changed1, changed2 = make(map[int]bool), make(map[int]bool)
+	for _, h := range hunks {
+		oldLine, newLine := h.OldStart, h.NewStart
+		for _, l := range h.Lines {
+			switch l[0] {
+			case '-':
+				changed1[oldLine] = true
+				changed2[newLine] = true
+				oldLine++
+			case '+':
+				changed2[newLine] = true
+				changed1[oldLine] = true
+				newLine++
+			default:
+				oldLine++
+				newLine++
+			}
+		}
+	}
+	return changed1, changed2
+}
+
+// invert maps each image path to its media name
+func invert(images map[string]string) map[string]string {
+	names := make(map[string]string, len(images))
+	for name, path := range images {
+		names[path] = name
+	}
+	return names
+}
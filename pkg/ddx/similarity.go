@@ -0,0 +1,62 @@
+package ddx
+
+import "strings"
+
+// Similarity scores how alike the two documents are, each in the range 0..1
+type Similarity struct {
+	Overall float64 // weighted mean of Text and Images
+	Text    float64 // fraction of markdown lines left unchanged
+	Images  float64 // fraction of images left unchanged
+}
+
+// countLines returns the number of lines in content, treating a missing
+// trailing newline as a final line.
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	n := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		n++
+	}
+	return n
+}
+
+// computeSimilarity combines text and image similarity.
+//
+// Text similarity is 2*unchanged / (lines1 + lines2), where unchanged is the
+// number of lines of the first document that the diff did not remove.
+// Image similarity is matched / (matched + changed + added + removed);
+// skipped images are not counted. A component with nothing to compare (no
+// lines, no images) is left out of the weighted mean.
+func computeSimilarity(r *Result, textWeight, imageWeight float64) Similarity {
+	if textWeight == 0 && imageWeight == 0 {
+		textWeight, imageWeight = 1, 1
+	}
+
+	sim := Similarity{Text: 1, Images: 1}
+
+	lines1 := countLines(r.Normalized1)
+	lines2 := countLines(r.Normalized2)
+	if lines1+lines2 == 0 {
+		textWeight = 0
+	} else {
+		unchanged := lines1 - r.DiffStat.Deletions
+		sim.Text = 2 * float64(unchanged) / float64(lines1+lines2)
+	}
+
+	m := r.MatchResult
+	images := len(m.Matched) + len(m.Different) + len(m.OnlyIn1) + len(m.OnlyIn2)
+	if images == 0 {
+		imageWeight = 0
+	} else {
+		sim.Images = float64(len(m.Matched)) / float64(images)
+	}
+
+	if textWeight+imageWeight == 0 {
+		sim.Overall = 1
+	} else {
+		sim.Overall = (textWeight*sim.Text + imageWeight*sim.Images) / (textWeight + imageWeight)
+	}
+	return sim
+}
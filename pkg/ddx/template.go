@@ -0,0 +1,192 @@
+package ddx
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/shioshosho/diff-docx/internal/diff"
+	"github.com/shioshosho/diff-docx/internal/markdown"
+)
+
+// Built-in report templates, usable in place of a template file
+const (
+	TemplateMarkdown = "markdown"
+	TemplateHTML     = "html"
+)
+
+// ReportTemplates lists the built-in report templates
+var ReportTemplates = []string{TemplateMarkdown, TemplateHTML}
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// ReportTemplate renders a Result with a Go template. Templates see the
+// fields of Report, as in the JSON report, plus Diff, the unified diff of the
+// markdown, and DiffLines, its lines classified for coloring. Functions:
+// base (file name of a path), psnr (a PSNR value, "inf" for identical),
+// bytes (a byte count such as "4.2 MB") and join.
+type ReportTemplate struct {
+	tmpl interface {
+		Execute(w io.Writer, data any) error
+	}
+	ext string // extension of the rendered file, e.g. ".md"
+}
+
+// reportData is what a ReportTemplate is executed with
+type reportData struct {
+	Report
+	Diff      string
+	DiffLines []DiffLine
+}
+
+// DiffLine is a line of the unified diff with its kind: "header" (the
+// ---/+++ lines), "hunk", "context", "del", "add", or "moved-from" and
+// "moved-to" for the lines of a block moved without changes, as found by
+// move detection, so that a report can color them like git's --color-moved.
+type DiffLine struct {
+	Text string
+	Kind string
+}
+
+// diffLines classifies the lines of unified, marking those within moves
+func diffLines(unified string, moves []markdown.Move) []DiffLine {
+	if unified == "" {
+		return nil
+	}
+	movedFrom := func(line int) bool {
+		return slices.ContainsFunc(moves, func(m markdown.Move) bool { return line >= m.OldStart && line <= m.OldEnd })
+	}
+	movedTo := func(line int) bool {
+		return slices.ContainsFunc(moves, func(m markdown.Move) bool { return line >= m.NewStart && line <= m.NewEnd })
+	}
+
+	var lines []DiffLine
+	oldLine, newLine := 0, 0
+	inHunks := false
+	for _, text := range strings.Split(strings.TrimSuffix(unified, "\n"), "\n") {
+		kind := "context"
+		switch {
+		case !inHunks && !strings.HasPrefix(text, "@@"):
+			kind = "header"
+		case strings.HasPrefix(text, "@@"):
+			kind, inHunks = "hunk", true
+			if h := diff.ParseHunks(text); len(h) == 1 {
+				oldLine, newLine = h[0].OldStart, h[0].NewStart
+			}
+		case strings.HasPrefix(text, "\\"):
+			// "\ No newline at end of file"
+		case strings.HasPrefix(text, "-"):
+			kind = "del"
+			if movedFrom(oldLine) {
+				kind = "moved-from"
+			}
+			oldLine++
+		case strings.HasPrefix(text, "+"):
+			kind = "add"
+			if movedTo(newLine) {
+				kind = "moved-to"
+			}
+			newLine++
+		default:
+			oldLine++
+			newLine++
+		}
+		lines = append(lines, DiffLine{Text: text, Kind: kind})
+	}
+	return lines
+}
+
+var templateFuncs = map[string]any{
+	"base": filepath.Base,
+	"psnr": func(psnr *float64) string {
+		if psnr == nil || math.IsInf(*psnr, 0) {
+			return "inf"
+		}
+		return fmt.Sprintf("%.3f", *psnr)
+	},
+	"bytes": FormatBytes,
+	"join":  strings.Join,
+}
+
+// LoadReportTemplate returns the built-in template called name, or parses
+// the text/template file name. Built-in html is an html/template, so that
+// names and diff lines are escaped.
+func LoadReportTemplate(name string) (*ReportTemplate, error) {
+	switch name {
+	case TemplateMarkdown:
+		tmpl, err := template.New("report.md.tmpl").Funcs(templateFuncs).ParseFS(builtinTemplates, "templates/report.md.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		return &ReportTemplate{tmpl: tmpl, ext: ".md"}, nil
+	case TemplateHTML:
+		tmpl, err := htmltemplate.New("report.html.tmpl").Funcs(templateFuncs).ParseFS(builtinTemplates, "templates/report.html.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		return &ReportTemplate{tmpl: tmpl, ext: ".html"}, nil
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report template: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(name)).Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse report template %s: %w", name, err)
+	}
+	// team.md.tmpl renders to .md; a template without an inner extension to .txt
+	ext := filepath.Ext(strings.TrimSuffix(filepath.Base(name), ".tmpl"))
+	if ext == "" {
+		ext = ".txt"
+	}
+	return &ReportTemplate{tmpl: tmpl, ext: ext}, nil
+}
+
+// Ext returns the extension of the rendered report, e.g. ".html"
+func (t *ReportTemplate) Ext() string {
+	return t.ext
+}
+
+// Execute renders the result to w
+func (t *ReportTemplate) Execute(w io.Writer, r *Result) error {
+	moves := r.Moves
+	if moves == nil {
+		moves = markdown.DetectMoves(r.Diff)
+	}
+	return t.tmpl.Execute(w, reportData{Report: r.Report(), Diff: r.Diff, DiffLines: diffLines(r.Diff, moves)})
+}
+
+// WriteFile renders the result to the file at path
+func (t *ReportTemplate) WriteFile(path string, r *Result) error {
+	var b strings.Builder
+	if err := t.Execute(&b, r); err != nil {
+		return fmt.Errorf("failed to render report: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// FormatBytes renders a byte count in decimal units, e.g. "4.2 MB"
+func FormatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
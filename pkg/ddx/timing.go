@@ -0,0 +1,53 @@
+package ddx
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StageTiming is the wall-clock time spent in one pipeline stage: extract,
+// convert, match, copy or diff
+type StageTiming struct {
+	Stage    string        `json:"stage"`
+	Duration time.Duration `json:"-"`
+	Seconds  float64       `json:"seconds"` // Duration in seconds, rounded to milliseconds
+}
+
+// FormatTimings renders timings as e.g. "extract 0.3s, convert 12.1s, match 48.7s"
+func FormatTimings(timings []StageTiming) string {
+	parts := make([]string, 0, len(timings))
+	for _, t := range timings {
+		parts = append(parts, fmt.Sprintf("%s %.1fs", t.Stage, t.Duration.Seconds()))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// stageTimer measures consecutive pipeline stages. Steps that share a stage
+// name, such as extracting each document, add up into one timing.
+type stageTimer struct {
+	timings []StageTiming
+	stage   string
+	start   time.Time
+}
+
+// begin ends the running stage, if any, and starts stage
+func (t *stageTimer) begin(stage string) {
+	t.end()
+	t.stage, t.start = stage, time.Now()
+}
+
+// end stops the running stage and records its time
+func (t *stageTimer) end() {
+	if t.stage == "" {
+		return
+	}
+	d := time.Since(t.start)
+	if n := len(t.timings); n > 0 && t.timings[n-1].Stage == t.stage {
+		t.timings[n-1].Duration += d
+		t.timings[n-1].Seconds = t.timings[n-1].Duration.Round(time.Millisecond).Seconds()
+	} else {
+		t.timings = append(t.timings, StageTiming{Stage: t.stage, Duration: d, Seconds: d.Round(time.Millisecond).Seconds()})
+	}
+	t.stage = ""
+}